@@ -0,0 +1,85 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Percona-Lab/sanitizer/internal/sanitize"
+	log "github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultAliasKeyFile = "~/.pt-secure-data/alias.key"
+	aliasKeySize        = 32
+)
+
+// newAliases returns a persistent aliases map using the key stored in keyFile if
+// persistent is true or a per-run aliases map otherwise. In dry run mode, a missing key
+// file is not created and a temporary key is used instead.
+func newAliases(persistent bool, keyFile string, dryRun bool) (*sanitize.Aliases, error) {
+	if !persistent {
+		return sanitize.NewAliases(), nil
+	}
+	key, err := loadAliasKey(keyFile, !dryRun)
+	if err != nil {
+		return nil, err
+	}
+	return sanitize.NewPersistentAliases(key), nil
+}
+
+// loadAliasKey reads the aliases key from keyFile. If the file doesn't exist, the key is
+// created and written into it if create is true, or only kept in memory otherwise.
+func loadAliasKey(keyFile string, create bool) ([]byte, error) {
+	buf, err := ioutil.ReadFile(keyFile)
+	if os.IsNotExist(err) {
+		if !create {
+			log.Infof("Aliases key file %q not found. Using a temporary key", keyFile)
+			return newAliasKey()
+		}
+		log.Infof("Creating aliases key file %q", keyFile)
+		return writeAliasKey(keyFile)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read aliases key file %q", keyFile)
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(buf)))
+	if err != nil || len(key) == 0 {
+		return nil, errors.Errorf("Invalid aliases key in %q", keyFile)
+	}
+	return key, nil
+}
+
+// newAliasKey returns a new random aliases key
+func newAliasKey() ([]byte, error) {
+	key := make([]byte, aliasKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrap(err, "Cannot generate a new aliases key")
+	}
+	return key, nil
+}
+
+// writeAliasKey writes a new random key into keyFile, replacing the existing one
+func writeAliasKey(keyFile string) ([]byte, error) {
+	key, err := newAliasKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyFile), 0700); err != nil {
+		return nil, errors.Wrapf(err, "Cannot create the directory for the aliases key file %q", keyFile)
+	}
+	if err := ioutil.WriteFile(keyFile, []byte(hex.EncodeToString(key)+"\n"), 0600); err != nil {
+		return nil, errors.Wrapf(err, "Cannot write aliases key file %q", keyFile)
+	}
+	return key, nil
+}
+
+func rotateAliasKey(opts *cliOptions) error {
+	log.Infof("Replacing aliases key in %q. Aliases from previous runs won't match new aliases.", *opts.RotateAliasKeyFile)
+	_, err := writeAliasKey(*opts.RotateAliasKeyFile)
+	return err
+}
@@ -35,10 +35,66 @@ func collectData(opts *cliOptions) error {
 
 	if !*opts.NoSanitize {
 		log.Infof("Sanitizing output collected data")
-		err := processFiles(*opts.TempDir, *opts.IncludeDirs, *opts.TempDir, !*opts.NoSanitizeHostnames, !*opts.NoSanitizeQueries)
+		aliases, err := newAliases(*opts.PersistentAliases, *opts.AliasKeyFile, false)
+		if err != nil {
+			return err
+		}
+		keep, err := keepPatterns(*opts.RulesFile, *opts.Keep)
+		if err != nil {
+			return err
+		}
+		knownHosts := append(learnHostnames(append([]string{*opts.TempDir}, *opts.IncludeDirs...)), *opts.KnownHosts...)
+		sopts, err := sanitizeOptions(*opts.Policy, ruleSwitches{
+			NoHostnames:        *opts.NoSanitizeHostnames,
+			NoQueries:          *opts.NoSanitizeQueries,
+			NoCredentials:      *opts.NoSanitizeCreds,
+			NoEmails:           *opts.NoSanitizeEmails,
+			NoIPs:              *opts.NoSanitizeIPs,
+			NoPaths:            *opts.NoSanitizePaths,
+			NoUUIDs:            *opts.NoSanitizeUUIDs,
+			NoSecrets:          *opts.NoSanitizeSecrets,
+			Identifiers:        *opts.SanitizeIdentifiers,
+			Users:              *opts.SanitizeUsers,
+			QueryMode:          *opts.QueryMode,
+			StripComments:      *opts.StripComments,
+			KeepHints:          *opts.KeepHints,
+			CommentKeys:        *opts.CommentKeys,
+			AliasCommentValues: *opts.AliasCommentValues,
+		})
+		if err != nil {
+			return err
+		}
+		sopts.KnownHosts = knownHosts
+		sopts.InternalSuffixes = *opts.InternalSuffixes
+		sopts.Keep = keep
+		log.Infof("Sanitization policy %q, rules: %s", *opts.Policy, strings.Join(sopts.Rules(), ", "))
+		sanitizer := sanitize.New(sopts, aliases)
+		if sopts.Queries && sopts.QueryMode == sanitize.QueryDigest {
+			if err = learnDigests(sanitizer, append([]string{*opts.TempDir}, *opts.IncludeDirs...)); err != nil {
+				return err
+			}
+		}
+		processed, err := processFiles(*opts.TempDir, *opts.IncludeDirs, *opts.TempDir, sanitizer, *opts.Review)
 		if err != nil {
 			return errors.Wrapf(err, "Cannot sanitize files in %q", *opts.TempDir)
 		}
+		if *opts.Review {
+			if err = reviewFiles(processed, os.Stdin, os.Stdout); err != nil {
+				return err
+			}
+		}
+		if *opts.MappingFile != "" {
+			if err = writeMappingFile(*opts.MappingFile, aliases, *opts.MappingPassword); err != nil {
+				return err
+			}
+		}
+		if err = writePolicyFile(*opts.TempDir, *opts.Policy, sopts); err != nil {
+			return err
+		}
+	} else {
+		if err := writePolicyFile(*opts.TempDir, policyNone, sanitize.Options{}); err != nil {
+			return err
+		}
 	}
 
 	tarFile := fmt.Sprintf(path.Join(*opts.TempDir, path.Base(*opts.TempDir)+".tar.gz"))
@@ -47,6 +103,14 @@ func collectData(opts *cliOptions) error {
 		return err
 	}
 
+	if *opts.Scan {
+		log.Infof("Scanning %q for possible leaks", tarFile)
+		secrets := append([]string{*opts.MySQLPass}, *opts.Secrets...)
+		if err := scanPath(tarFile, secrets, os.Stdout); err != nil {
+			return errors.Wrap(err, "The output file was not encrypted")
+		}
+	}
+
 	if !*opts.NoEncrypt && *opts.EncryptPassword != "" {
 		password := sha256.Sum256([]byte(*opts.EncryptPassword))
 		encryptedFile := fmt.Sprintf(path.Join(*opts.TempDir, path.Base(*opts.TempDir)+".aes"))
@@ -57,17 +121,21 @@ func collectData(opts *cliOptions) error {
 	return nil
 }
 
-func processFiles(dataDir string, includeDirs []string, outputDir string, sanitizeHostnames, sanitizeQueries bool) error {
+// processFiles sanitizes the files in dataDir and includeDirs and writes them into outputDir.
+// If keepOriginals is true, it returns the original and sanitized contents of every file.
+func processFiles(dataDir string, includeDirs []string, outputDir string, sanitizer *sanitize.Sanitizer,
+	keepOriginals bool) ([]processedFile, error) {
 	dirs := []string{dataDir}
 	dirs = append(dirs, includeDirs...)
+	processed := []processedFile{}
 
 	for _, dir := range dirs {
 		files, err := ioutil.ReadDir(dir)
 		if err != nil {
-			return errors.Wrapf(err, "Cannot get the listing of %q", dir)
+			return nil, errors.Wrapf(err, "Cannot get the listing of %q", dir)
 		}
 		if len(files) == 0 {
-			return errors.Errorf("There are no files to sanitize in %q", dir)
+			return nil, errors.Errorf("There are no files to sanitize in %q", dir)
 		}
 		log.Debug("Sanitization process start")
 
@@ -79,27 +147,86 @@ func processFiles(dataDir string, includeDirs []string, outputDir string, saniti
 			log.Debugf("Reading %q", inputFile)
 			fh, err := os.Open(inputFile)
 			if err != nil {
-				return errors.Wrapf(err, "Cannot open %q for reading", inputFile)
+				return nil, errors.Wrapf(err, "Cannot open %q for reading", inputFile)
 			}
 
 			lines, err := util.ReadLinesFromFile(fh)
+			fh.Close()
 			if err != nil {
-				return errors.Wrapf(err, "Cannot sanitize %q", inputFile)
+				return nil, errors.Wrapf(err, "Cannot sanitize %q", inputFile)
+			}
+			var original []string
+			if keepOriginals {
+				original = append(original, lines...)
 			}
 
-			log.Debugf("Sanitizing %q", inputFile)
-			sanitized := sanitize.Sanitize(lines, sanitizeHostnames, sanitizeQueries)
+			fileType, detectedBy := sanitize.Classify(file.Name(), lines)
+			logFileType(inputFile, fileType, detectedBy)
+			sanitized := sanitizer.SanitizeFile(fileType, lines)
 
-			outfile := path.Join(outputDir, file.Name())
+			outfile := path.Join(outputDir, sanitizer.SanitizeFileName(file.Name()))
 			log.Debugf("Writing sanitized file to %q", outfile)
 			ofh, err := os.Create(outfile)
 			if err != nil {
-				return errors.Wrapf(err, "Cannot open %q for writing", outfile)
+				return nil, errors.Wrapf(err, "Cannot open %q for writing", outfile)
+			}
+
+			err = util.WriteLinesToFile(ofh, sanitized)
+			ofh.Close()
+			if err != nil {
+				return nil, errors.Wrapf(err, "Cannot write sanitized file %q", outfile)
 			}
+			// The original file must not end up in the tar file if the name had a host name
+			if outfile != inputFile && path.Dir(inputFile) == path.Clean(outputDir) {
+				if err = os.Remove(inputFile); err != nil {
+					return nil, errors.Wrapf(err, "Cannot remove %q", inputFile)
+				}
+			}
+
+			if keepOriginals {
+				processed = append(processed, processedFile{
+					Name:      inputFile,
+					Outfile:   outfile,
+					Original:  original,
+					Sanitized: sanitized,
+				})
+			}
+		}
+	}
+	return processed, nil
+}
 
-			if err = util.WriteLinesToFile(ofh, sanitized); err != nil {
-				return errors.Wrapf(err, "Cannot write sanitized file %q", outfile)
+// logFileType logs the sanitizer used for a file and why
+func logFileType(filename, fileType, detectedBy string) {
+	sanitizer := "generic"
+	if sanitize.HasFileSanitizer(fileType) {
+		sanitizer = fileType
+	}
+	log.Infof("Sanitizing %q, file type %s (%s), using the %s sanitizer", filename, fileType, detectedBy, sanitizer)
+}
+
+// learnDigests reads the performance_schema digests in the files in dirs so queries in
+// other files get the same digests
+func learnDigests(sanitizer *sanitize.Sanitizer, dirs []string) error {
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return errors.Wrapf(err, "Cannot get the listing of %q", dir)
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			fh, err := os.Open(path.Join(dir, file.Name()))
+			if err != nil {
+				return errors.Wrapf(err, "Cannot open %q for reading", file.Name())
+			}
+			lines, err := util.ReadLinesFromFile(fh)
+			fh.Close()
+			if err != nil {
+				return errors.Wrapf(err, "Cannot read %q", file.Name())
 			}
+			sanitizer.LearnDigests(lines)
 		}
 	}
 	return nil
@@ -3,14 +3,17 @@ package main
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/rand"
 	"crypto/sha256"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
 )
 
 func encryptorCmd(opts *cliOptions) (err error) {
@@ -64,6 +67,88 @@ func encrypt(infile, outfile string, pass [32]byte) error {
 	return nil
 }
 
+// The scrypt parameters used to derive the encryption keys of encryptData, as recommended
+// for interactive logins
+const (
+	scryptSaltSize = 16
+	scryptN        = 32768
+	scryptR        = 8
+	scryptP        = 1
+)
+
+// encryptData writes data into outfile encrypted with AES-GCM. The key is derived from
+// password with scrypt and a random salt, which is written as the header of the file,
+// followed by a random nonce and the ciphertext, so the same password can be used for more
+// than one file. The header is authenticated with the ciphertext.
+func encryptData(data []byte, outfile string, password string) error {
+	salt := make([]byte, scryptSaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return errors.Wrap(err, "Cannot generate a random salt")
+	}
+	gcm, err := newScryptGCM(password, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return errors.Wrap(err, "Cannot generate a random nonce")
+	}
+
+	outFile, err := os.OpenFile(outfile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot create output file %q", outfile)
+	}
+	defer outFile.Close()
+
+	header := append(salt, nonce...)
+	if _, err := outFile.Write(gcm.Seal(header, nonce, data, salt)); err != nil {
+		return errors.Wrapf(err, "Cannot write to output file %q", outfile)
+	}
+	return nil
+}
+
+// decryptData returns the decrypted content of infile, written by encryptData
+func decryptData(infile string, password string) ([]byte, error) {
+	buf, err := ioutil.ReadFile(infile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read %q", infile)
+	}
+	if len(buf) < scryptSaltSize {
+		return nil, errors.Errorf("Cannot decrypt %q: the file is too short", infile)
+	}
+	salt, buf := buf[:scryptSaltSize], buf[scryptSaltSize:]
+	gcm, err := newScryptGCM(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < gcm.NonceSize() {
+		return nil, errors.Errorf("Cannot decrypt %q: the file is too short", infile)
+	}
+	nonce, ciphertext := buf[:gcm.NonceSize()], buf[gcm.NonceSize():]
+	data, err := gcm.Open(nil, nonce, ciphertext, salt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot decrypt %q. Is the password correct?", infile)
+	}
+	return data, nil
+}
+
+// newScryptGCM returns an AES-256-GCM cipher using the key derived from password and salt
+func newScryptGCM(password string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(password), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot derive the encryption key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot create the cipher")
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot create the GCM cipher")
+	}
+	return gcm, nil
+}
+
 func decrypt(infile, outfile string, pass [32]byte) error {
 	key := pass[:]
 	inFile, err := os.Open(infile)
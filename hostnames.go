@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+const (
+	etcHosts = "/etc/hosts"
+	// minShortHostnameLength is the minimum length of the known host names without a domain
+	minShortHostnameLength = 5
+)
+
+var (
+	// Matches the hostname and report_host variables in SHOW VARIABLES output, in table or
+	// tab separated format, and the Hostname line in pt-summary output.
+	hostnameVarRE = regexp.MustCompile(`(?i)^\s*\|?\s*(hostname|report_host)\s*[|\t]\s*([A-Za-z0-9][A-Za-z0-9_.\-]*)\s*\|?\s*$`)
+	// Names for the loopback addresses that don't identify the server
+	loopbackNames = map[string]bool{
+		"localhost":             true,
+		"localhost.localdomain": true,
+		"ip6-localhost":         true,
+		"ip6-loopback":          true,
+	}
+)
+
+// learnHostnames returns the names of this server: the host name and its short form, its
+// names in /etc/hosts and the @@hostname and @@report_host values found in the files
+// collected by pt-stalk and pt-summary in dirs.
+func learnHostnames(dirs []string) []string {
+	hostnames := []string{}
+	if hostname, err := os.Hostname(); err == nil {
+		hostnames = append(hostnames, hostname)
+	}
+	hostnames = append(hostnames, etcHostsNames(etcHosts, hostnames)...)
+
+	for _, dir := range dirs {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if file.IsDir() {
+				continue
+			}
+			hostnames = append(hostnames, collectedHostnames(path.Join(dir, file.Name()))...)
+		}
+	}
+
+	known := knownHostnames(hostnames)
+	log.Debugf("Known host names: %v", known)
+	return known
+}
+
+// knownHostnames returns the lowercase names, and their short forms, that can be replaced
+// wherever they appear. Loopback names are skipped, and so are the short names that are too
+// common, like mysql or db: they must be at least minShortHostnameLength characters long
+// and contain a digit or a hyphen, like db-prod-07 or web01.
+func knownHostnames(hostnames []string) []string {
+	known := []string{}
+	seen := make(map[string]bool)
+	for _, hostname := range hostnames {
+		// Also add db-prod-07 for db-prod-07.example.com
+		for _, name := range []string{hostname, strings.SplitN(hostname, ".", 2)[0]} {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" || loopbackNames[name] || seen[name] {
+				continue
+			}
+			seen[name] = true
+			if !strings.Contains(name, ".") && (len(name) < minShortHostnameLength || !strings.ContainsAny(name, "0123456789-")) {
+				log.Debugf("Skipping the host name %q, too common to be replaced everywhere", name)
+				continue
+			}
+			known = append(known, name)
+		}
+	}
+	return known
+}
+
+// etcHostsNames returns the names in filename given to the loopback addresses or to any of
+// the hostnames.
+func etcHostsNames(filename string, hostnames []string) []string {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer fh.Close()
+
+	names := []string{}
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		fields := strings.Fields(strings.SplitN(scanner.Text(), "#", 2)[0])
+		if len(fields) < 2 {
+			continue
+		}
+		own := strings.HasPrefix(fields[0], "127.") || fields[0] == "::1"
+		for _, name := range fields[1:] {
+			for _, hostname := range hostnames {
+				if strings.EqualFold(name, hostname) {
+					own = true
+				}
+			}
+		}
+		if own {
+			names = append(names, fields[1:]...)
+		}
+	}
+	return names
+}
+
+// collectedHostnames returns the host names found in a file written by the data collection
+// tools: pt-stalk's hostname file and the hostname and report_host variables.
+func collectedHostnames(filename string) []string {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return nil
+	}
+	defer fh.Close()
+
+	names := []string{}
+	isHostnameFile := strings.HasSuffix(filename, "-hostname")
+	scanner := bufio.NewScanner(fh)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if isHostnameFile {
+			names = append(names, strings.Fields(line)...)
+			continue
+		}
+		if m := hostnameVarRE.FindStringSubmatch(line); m != nil {
+			names = append(names, m[2])
+		}
+	}
+	return names
+}
@@ -0,0 +1,169 @@
+package sanitize
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Alias kinds. The kind is also used as the prefix of the generated alias.
+const (
+	KindDatabase = "db"
+	KindTable    = "t"
+	// Table aliases, like o in FROM orders o, are aliased apart from the tables
+	KindTableAlias = "a"
+	KindColumn     = "c"
+	KindIndex      = "idx"
+	KindHost       = "host"
+	KindUser       = "user"
+	KindIP         = "ip"
+	KindTag        = "tag"
+	KindDir        = "dir"
+	KindFile       = "file"
+	// UUIDs are aliased by other UUIDs, like 00000000-0000-0000-0000-000000000001
+	KindUUID = "uuid"
+	// Server ids are aliased by other numbers
+	KindServerID = "server-id"
+)
+
+var (
+	aliasFormats = map[string]string{
+		KindHost:     "%s-%04d",
+		KindUser:     "%s-%04d",
+		KindIP:       "%s-%04d",
+		KindTag:      "%s-%04d",
+		KindDir:      "%s-%04d",
+		KindFile:     "%s-%04d",
+		KindUUID:     "%.0s00000000-0000-0000-0000-%012d",
+		KindServerID: "%.0s%d",
+	}
+	persistentAliasFormats = map[string]string{
+		KindHost: "%s-%s",
+		KindUser: "%s-%s",
+		KindIP:   "%s-%s",
+		KindTag:  "%s-%s",
+		KindDir:  "%s-%s",
+		KindFile: "%s-%s",
+	}
+)
+
+// Length, in hex digits, of the hash used by persistent aliases
+const persistentAliasLength = 8
+
+// Aliases keeps a consistent mapping between original values and their aliases so the
+// same value gets the same alias in every file of a data collection.
+type Aliases struct {
+	mu        sync.Mutex
+	aliases   map[string]map[string]string
+	originals map[string]map[string]string
+	counters  map[string]int
+	key       []byte
+}
+
+// NewAliases returns an empty aliases map
+func NewAliases() *Aliases {
+	return &Aliases{
+		aliases:   make(map[string]map[string]string),
+		originals: make(map[string]map[string]string),
+		counters:  make(map[string]int),
+	}
+}
+
+// NewPersistentAliases returns an empty aliases map where aliases are derived from key
+// instead of being sequential, so the same value gets the same alias in every run using
+// the same key.
+func NewPersistentAliases(key []byte) *Aliases {
+	a := NewAliases()
+	a.key = key
+	return a
+}
+
+// Get returns the alias for value. A new alias is created the first time a value is seen.
+// Values are case insensitive.
+func (a *Aliases) Get(kind, value string) string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := strings.ToLower(value)
+	if _, ok := a.aliases[kind]; !ok {
+		a.aliases[kind] = make(map[string]string)
+		a.originals[kind] = make(map[string]string)
+	}
+	if alias, ok := a.aliases[kind][key]; ok {
+		return alias
+	}
+	var alias string
+	if a.key != nil {
+		alias = a.persistentAlias(kind, key)
+	} else {
+		format, ok := aliasFormats[kind]
+		if !ok {
+			format = "%s%d"
+		}
+		a.counters[kind]++
+		alias = fmt.Sprintf(format, kind, a.counters[kind])
+	}
+	a.aliases[kind][key] = alias
+	a.originals[kind][alias] = value
+	return alias
+}
+
+// persistentAlias returns an alias built from the HMAC of the value. In the unlikely case
+// of a collision, the hash is made longer.
+func (a *Aliases) persistentAlias(kind, value string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(kind + ":" + value))
+	sum := hex.EncodeToString(mac.Sum(nil))
+
+	format, ok := persistentAliasFormats[kind]
+	if !ok {
+		format = "%s_%s"
+	}
+	if kind == KindUUID {
+		return fmt.Sprintf("%s-%s-%s-%s-%s", sum[:8], sum[8:12], sum[12:16], sum[16:20], sum[20:32])
+	}
+	if kind == KindServerID {
+		// Server ids are 32 bits unsigned integers
+		id, _ := strconv.ParseUint(sum[:8], 16, 32)
+		return strconv.FormatUint(id, 10)
+	}
+	for length := persistentAliasLength; ; length += 2 {
+		alias := fmt.Sprintf(format, kind, sum[:length])
+		if _, used := a.originals[kind][alias]; !used || length >= len(sum) {
+			return alias
+		}
+	}
+}
+
+// IsAlias returns true if value is an alias of any kind, so values already sanitized are
+// not aliased again
+func (a *Aliases) IsAlias(value string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, originals := range a.originals {
+		if _, ok := originals[value]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Mapping returns, for each kind, the map from aliases to the original values
+func (a *Aliases) Mapping() map[string]map[string]string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	mapping := make(map[string]map[string]string, len(a.originals))
+	for kind, originals := range a.originals {
+		mapping[kind] = make(map[string]string, len(originals))
+		for alias, value := range originals {
+			mapping[kind][alias] = value
+		}
+	}
+	return mapping
+}
@@ -0,0 +1,182 @@
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RedactedBase64 replaces the base64 encoded events of the BINLOG statements
+const RedactedBase64 = "<base64>"
+
+var (
+	// The header of an event: the time, the server id, the positions and the event type,
+	// like in #180305 13:25:01 server id 1  end_log_pos 291 CRC32 0x1a2b3c4d 	Query
+	binlogEventRE  = regexp.MustCompile(`^(#\d{6}\s+\d?\d:\d\d:\d\d\s+server id\s+)(\d+)(\s+end_log_pos\s+\d+.*)$`)
+	binlogRotateRE = regexp.MustCompile(`(\tRotate to )(\S+)`)
+	// The column values of the row images printed by mysqlbinlog -v, like
+	// ###   @2='alice' /* VARSTRING(60) meta=60 nullable=1 is_null=0 */
+	binlogRowValueRE  = regexp.MustCompile(`^(###\s+@\d+=)(.*?)((?:\s+/\*.*\*/)?)$`)
+	binlogDelimiterRE = regexp.MustCompile(`^DELIMITER\s+(\S+)`)
+	// Statements that set the context of the events, like SET TIMESTAMP=1520256301. They are
+	// kept, but for the UUIDs of GTID_NEXT.
+	binlogSessionRE = regexp.MustCompile(`(?i)^(/\*!\d*\s*)?(SET\s+(@@|@OLD_|COMPLETION_TYPE|TIMESTAMP|INSERT_ID|LAST_INSERT_ID|PSEUDO_|TRANSACTION|NAMES|CHARACTER)|BEGIN|COMMIT|ROLLBACK|XA\s|\\C\s)`)
+)
+
+// sanitizeBinlogFile sanitizes the output of mysqlbinlog. The row images and the base64
+// encoded events are masked, the statements are sanitized like any other query and the
+// server ids are aliased, while the positions, times, GTIDs and event types are kept.
+// Statements end with the delimiter set by mysqlbinlog, so multi-line statements and
+// compound statements are sanitized as a whole.
+func (s *Sanitizer) sanitizeBinlogFile(lines []string) []string {
+	sanitized := make([]string, 0, len(lines))
+	delimiter := ";"
+	statement := []string{}
+	rowsQuery := []string{}
+	base64 := []string{}
+	inBase64 := false
+	inRowsQuery := false
+
+	flushStatement := func() {
+		if len(statement) > 0 {
+			sanitized = append(sanitized, strings.Split(s.sanitizeBinlogStatement(statement, delimiter), "\n")...)
+			statement = statement[:0]
+		}
+	}
+	for _, line := range lines {
+		s.line++
+		// The query of a Rows_query event is printed by mysqlbinlog -vv as comment lines
+		if inRowsQuery && (!strings.HasPrefix(line, "# ") || strings.HasPrefix(line, "# at ")) {
+			inRowsQuery = false
+			if len(rowsQuery) > 0 {
+				sanitized = append(sanitized, "# "+s.sanitizeBinlogQuery(strings.Join(rowsQuery, "\n")))
+				rowsQuery = rowsQuery[:0]
+			}
+		}
+		switch {
+		case inBase64:
+			if !strings.HasPrefix(line, "'") {
+				base64 = append(base64, line)
+				continue
+			}
+			inBase64 = false
+			if s.opts.Queries {
+				sanitized = append(sanitized, s.replace(RuleQueries, strings.Join(base64, "\n"), RedactedBase64))
+			} else {
+				sanitized = append(sanitized, base64...)
+			}
+			sanitized = append(sanitized, line)
+			base64 = base64[:0]
+		case len(statement) > 0:
+			statement = append(statement, line)
+			if strings.HasSuffix(line, delimiter) {
+				flushStatement()
+			}
+		case inRowsQuery:
+			rowsQuery = append(rowsQuery, strings.TrimPrefix(line, "# "))
+		case line == "":
+			sanitized = append(sanitized, line)
+		case strings.HasPrefix(line, "###"):
+			sanitized = append(sanitized, s.sanitizeBinlogRow(line))
+		case strings.HasPrefix(line, "#"):
+			if m := binlogEventRE.FindStringSubmatch(line); m != nil {
+				inRowsQuery = strings.Contains(m[3], "\tRows_query")
+				line = m[1] + s.aliasServerID(m[2]) + s.sanitizeBinlogEvent(m[3])
+			} else if s.opts.UUIDs {
+				// The GTID sets of the Previous-GTIDs events
+				line = s.aliasUUIDs(line)
+			}
+			sanitized = append(sanitized, line)
+		case binlogDelimiterRE.MatchString(line):
+			delimiter = binlogDelimiterRE.FindStringSubmatch(line)[1]
+			sanitized = append(sanitized, line)
+		case line == "BINLOG '":
+			inBase64 = true
+			sanitized = append(sanitized, line)
+		default:
+			statement = append(statement, line)
+			if strings.HasSuffix(line, delimiter) {
+				flushStatement()
+			}
+		}
+	}
+	flushStatement()
+	if len(rowsQuery) > 0 {
+		sanitized = append(sanitized, "# "+s.sanitizeBinlogQuery(strings.Join(rowsQuery, "\n")))
+	}
+	return sanitized
+}
+
+// sanitizeBinlogEvent sanitizes the event type and info that follow the server id in the
+// header of an event
+func (s *Sanitizer) sanitizeBinlogEvent(event string) string {
+	if m := binlogRotateRE.FindStringSubmatchIndex(event); m != nil {
+		return event[:m[3]] + s.sanitizeLogFileName(event[m[4]:m[5]]) + event[m[5]:]
+	}
+	if s.opts.Identifiers {
+		// The tables of the Table_map events, like `shop`.`customers`
+		return s.obfuscateStructuredIdentifiers(event)
+	}
+	return event
+}
+
+// sanitizeBinlogRow masks the column values of the row images, keeping the column types
+// and NULL values, and aliases the table names
+func (s *Sanitizer) sanitizeBinlogRow(line string) string {
+	if m := binlogRowValueRE.FindStringSubmatch(line); m != nil {
+		if !s.opts.Queries || m[2] == "NULL" {
+			return line
+		}
+		return m[1] + s.replace(RuleQueries, m[2], "?") + m[3]
+	}
+	if s.opts.Identifiers {
+		return s.obfuscateStructuredIdentifiers(line)
+	}
+	return line
+}
+
+// sanitizeBinlogStatement sanitizes the lines of a statement ending with delimiter. The
+// statements setting the session context are kept and the other ones, whatever their
+// verb, are sanitized as queries.
+func (s *Sanitizer) sanitizeBinlogStatement(lines []string, delimiter string) string {
+	text := strings.TrimSuffix(strings.Join(lines, "\n"), delimiter)
+	// The delimiter can be on its own line, like after BEGIN
+	if strings.HasSuffix(text, "\n") {
+		text = strings.TrimSuffix(text, "\n")
+		delimiter = "\n" + delimiter
+	}
+	switch {
+	case strings.TrimSpace(text) == "":
+	case binlogSessionRE.MatchString(text):
+		if s.opts.UUIDs {
+			text = s.aliasUUIDs(text)
+		}
+	case useDbRe.MatchString(text):
+		if s.opts.Identifiers {
+			text = s.obfuscateStructuredIdentifiers(text)
+		}
+	default:
+		text = s.sanitizeBinlogQuery(text)
+	}
+	return text + delimiter
+}
+
+// sanitizeBinlogQuery sanitizes a statement based event or the query of a Rows_query event
+func (s *Sanitizer) sanitizeBinlogQuery(query string) string {
+	if s.opts.Queries || s.opts.Identifiers {
+		return s.sanitizeQuery(query)
+	}
+	physical := strings.Split(query, "\n")
+	for i := range physical {
+		physical[i] = s.sanitizeLine(physical[i])
+	}
+	return strings.Join(physical, "\n")
+}
+
+// aliasServerID aliases a server id, like the ones of the binary log events, by another
+// number if UUIDs are sanitized. 0, the id of the events created by mysqlbinlog, is kept.
+func (s *Sanitizer) aliasServerID(id string) string {
+	if !s.opts.UUIDs || id == "" || id == "0" {
+		return id
+	}
+	return s.replace(RuleUUIDs, id, s.alias(KindServerID, id))
+}
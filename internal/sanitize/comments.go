@@ -0,0 +1,161 @@
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DefaultCommentKeys are the keys of the sqlcommenter and marginalia tags that are kept
+// by the standard policy
+var DefaultCommentKeys = []string{
+	"action", "application", "controller", "db_driver", "framework", "job", "route", "traceparent",
+}
+
+var (
+	// Tags written by sqlcommenter, like controller='orders', and by marginalia, like
+	// controller:orders
+	commentTagRE = regexp.MustCompile(`([A-Za-z_][\w.\-]*)\s*([:=])\s*('(?:[^'\\]|\\.)*'|[^\s,'*]+)`)
+
+	// Hints whose first argument is a table and the others are indexes
+	indexHints = map[string]bool{
+		"GROUP_INDEX": true, "INDEX": true, "INDEX_MERGE": true, "JOIN_INDEX": true, "MRR": true,
+		"NO_GROUP_INDEX": true, "NO_ICP": true, "NO_INDEX": true, "NO_INDEX_MERGE": true, "NO_JOIN_INDEX": true,
+		"NO_MRR": true, "NO_ORDER_INDEX": true, "NO_RANGE_OPTIMIZATION": true, "NO_SKIP_SCAN": true,
+		"ORDER_INDEX": true, "SKIP_SCAN": true,
+	}
+	// Hints whose arguments are tables
+	tableHints = map[string]bool{
+		"BKA": true, "BNL": true, "DERIVED_CONDITION_PUSHDOWN": true, "HASH_JOIN": true, "JOIN_FIXED_ORDER": true,
+		"JOIN_ORDER": true, "JOIN_PREFIX": true, "JOIN_SUFFIX": true, "MERGE": true, "NO_BKA": true, "NO_BNL": true,
+		"NO_DERIVED_CONDITION_PUSHDOWN": true, "NO_HASH_JOIN": true, "NO_MERGE": true,
+	}
+)
+
+// hasKeptComments returns true if some comments survive the replacement of queries
+func (o Options) hasKeptComments() bool {
+	return o.KeepHints || len(o.CommentKeys) > 0
+}
+
+// withKeptComments adds the comments of q kept by the options to its sanitized version.
+// Optimizer hints go after the first keyword, where MySQL expects them, and tags go to
+// the end of the query, where sqlcommenter and marginalia write them.
+func (s *Sanitizer) withKeptComments(q, sanitized string) string {
+	if !s.opts.hasKeptComments() {
+		return sanitized
+	}
+	hints, tags := []string{}, []string{}
+	for _, tok := range tokenize(q) {
+		if tok.kind != tokenComment {
+			continue
+		}
+		if strings.HasPrefix(tok.text, "/*+") {
+			if s.opts.KeepHints {
+				hints = append(hints, s.sanitizeHint(tok.text))
+			}
+			continue
+		}
+		if tag := s.sanitizeCommentTags(tok.text); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(hints) > 0 {
+		sanitized = strings.TrimSpace(sanitized)
+		end := strings.IndexFunc(sanitized, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' })
+		if end < 0 {
+			end = len(sanitized)
+		}
+		sanitized = sanitized[:end] + " " + strings.Join(hints, " ") + sanitized[end:]
+	}
+	if len(tags) > 0 {
+		sanitized = strings.TrimRight(sanitized, " ;") + " " + strings.Join(tags, " ")
+	}
+	return sanitized
+}
+
+// sanitizeHint aliases the tables and indexes in an optimizer hint comment if the
+// identifiers rule is enabled
+func (s *Sanitizer) sanitizeHint(hint string) string {
+	if !s.opts.Identifiers {
+		return hint
+	}
+	tokens := tokenize(hint[3 : len(hint)-2])
+	name := ""
+	arg := 0
+	depth := 0
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokenPunct:
+			switch tok.text {
+			case "(":
+				depth++
+				arg = 0
+			case ")":
+				depth--
+			}
+		case tokenWord, tokenQuotedIdent:
+			if depth == 0 {
+				name = strings.ToUpper(tok.text)
+				continue
+			}
+			kind := ""
+			switch {
+			case indexHints[name] && arg == 0:
+				kind = KindTable
+			case indexHints[name]:
+				kind = KindIndex
+			case tableHints[name]:
+				kind = KindTable
+			}
+			arg++
+			if kind == "" {
+				continue
+			}
+			original := unquote(tok.text)
+			tokens[i].text = s.replace(RuleIdentifiers, original, s.alias(kind, original))
+		}
+	}
+	return "/*+" + joinTokens(tokens) + "*/"
+}
+
+// sanitizeCommentTags returns a comment with the tags of comment whose keys are in
+// Options.CommentKeys or an empty string if there are none. Their values are aliased if
+// Options.AliasCommentValues is set.
+func (s *Sanitizer) sanitizeCommentTags(comment string) string {
+	if len(s.opts.CommentKeys) == 0 || !strings.HasPrefix(comment, "/*") {
+		return ""
+	}
+	tags := []string{}
+	sep := " "
+	for _, m := range commentTagRE.FindAllStringSubmatch(comment, -1) {
+		if !s.isCommentKey(m[1]) {
+			continue
+		}
+		if m[2] == "=" {
+			sep = ","
+		}
+		value := m[3]
+		if s.opts.AliasCommentValues {
+			quote := ""
+			if strings.HasPrefix(value, "'") {
+				quote = "'"
+			}
+			value = strings.Trim(value, "'")
+			value = quote + s.replace(RuleQueries, value, s.alias(KindTag, value)) + quote
+		}
+		tags = append(tags, m[1]+m[2]+value)
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	return "/* " + strings.Join(tags, sep) + " */"
+}
+
+func (s *Sanitizer) isCommentKey(key string) bool {
+	for _, k := range s.opts.CommentKeys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,231 @@
+package sanitize
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DigestPrefix is the prefix of the performance_schema digests that replace queries in
+	// QueryDigest mode
+	DigestPrefix = "digest:"
+	// DigestFallbackPrefix is the prefix of the hashes that replace the queries whose digest
+	// is not known. They are computed from an approximation of DIGEST_TEXT and cannot be
+	// compared with the performance_schema DIGEST values.
+	DigestFallbackPrefix = "digest~"
+)
+
+var (
+	// Lists of values, as written in queries and in performance_schema DIGEST_TEXT
+	digestListRE = regexp.MustCompile(`\( (?:\? (?:, \? )*|\. \. \. )\)`)
+	// Multi-row VALUES lists
+	digestRowsRE = regexp.MustCompile(`\(\?\+\)(?: , \(\?\+\))+`)
+
+	digestRowRE     = regexp.MustCompile(`^\s*DIGEST:\s*([0-9A-Fa-f]{32,64})\s*$`)
+	digestTextRowRE = regexp.MustCompile(`^\s*DIGEST_TEXT:\s*(.+?)\s*$`)
+)
+
+// queryDigest returns the digest replacing q. If q has the same digest text as a
+// performance_schema digest learnt with LearnDigests, the performance_schema DIGEST is
+// used so the query can be correlated with the statement summary tables. Otherwise, q is
+// replaced by a hash of its digest text, marked with DigestFallbackPrefix.
+func (s *Sanitizer) queryDigest(q string) string {
+	text := digestText(q)
+	if digest, ok := s.digests[text]; ok {
+		return DigestPrefix + digest
+	}
+	sum := sha256.Sum256([]byte(text))
+	return DigestFallbackPrefix + hex.EncodeToString(sum[:])
+}
+
+// LearnDigests reads the DIGEST and DIGEST_TEXT columns of the performance_schema
+// statement summary tables found in lines. Both vertical (\G) and tab or | separated
+// outputs are supported. It must be called before Sanitize.
+func (s *Sanitizer) LearnDigests(lines []string) {
+	for _, row := range findDigestRows(lines) {
+		if row.digest != "" {
+			s.digests[digestText(row.text)] = row.digest
+		}
+	}
+}
+
+// replaceDigestTexts replaces the DIGEST_TEXT values found in lines by the DIGEST of their
+// rows, or by a fallback hash if the row has no DIGEST, so the normalized statements,
+// that still have the names of the tables and columns, are not kept in QueryDigest mode.
+// Shorter values are padded with spaces so the columns of | separated tables stay aligned.
+func (s *Sanitizer) replaceDigestTexts(lines []string) {
+	for _, row := range findDigestRows(lines) {
+		replacement := DigestPrefix + row.digest
+		if row.digest == "" {
+			replacement = s.queryDigest(row.text)
+		}
+		s.line = row.line + 1
+		replacement = s.replace(RuleQueries, row.text, replacement)
+		if pad := row.end - row.start - len(replacement); pad > 0 && row.padded {
+			replacement += strings.Repeat(" ", pad)
+		}
+		line := lines[row.line]
+		lines[row.line] = line[:row.start] + replacement + line[row.end:]
+	}
+}
+
+// digestRow is a DIGEST_TEXT value of a performance_schema output
+type digestRow struct {
+	// line is the index of the line with the value, that is line[start:end]
+	line, start, end int
+	text             string
+	// digest is the DIGEST of the row, if known, in lower case
+	digest string
+	// padded is true if the value is in a column padded with spaces
+	padded bool
+}
+
+// findDigestRows returns the DIGEST_TEXT values found in the vertical (\G) and tab or |
+// separated outputs of the performance_schema statement tables in lines
+func findDigestRows(lines []string) []digestRow {
+	rows := []digestRow{}
+	digest := ""
+	header := map[string]int{}
+	sep := ""
+	for i, line := range lines {
+		// Vertical output
+		if strings.HasPrefix(line, "***") {
+			digest = ""
+			continue
+		}
+		if m := digestRowRE.FindStringSubmatch(line); m != nil {
+			digest = strings.ToLower(m[1])
+			continue
+		}
+		if m := digestTextRowRE.FindStringSubmatchIndex(line); m != nil {
+			rows = append(rows, digestRow{line: i, start: m[2], end: m[3], text: line[m[2]:m[3]], digest: digest})
+			digest = ""
+			continue
+		}
+
+		// Tabular output: a header with DIGEST and DIGEST_TEXT columns and one line per row
+		for _, candidate := range []string{"\t", "|"} {
+			fields := splitColumns(line, candidate)
+			if len(fields) < 2 {
+				continue
+			}
+			columns := map[string]int{}
+			for i, field := range fields {
+				columns[strings.ToUpper(field)] = i
+			}
+			_, hasDigest := columns["DIGEST"]
+			_, hasText := columns["DIGEST_TEXT"]
+			if hasDigest && hasText {
+				header, sep = columns, candidate
+				break
+			}
+		}
+		if sep == "" {
+			continue
+		}
+		spans := columnSpans(line, sep)
+		if len(spans) != len(header) {
+			if !strings.HasPrefix(line, "+") {
+				header, sep = map[string]int{}, ""
+			}
+			continue
+		}
+		span := spans[header["DIGEST_TEXT"]]
+		text := line[span[0]:span[1]]
+		if strings.EqualFold(text, "DIGEST_TEXT") {
+			continue
+		}
+		row := digestRow{line: i, start: span[0], end: span[1], text: text, padded: sep == "|"}
+		value := line[spans[header["DIGEST"]][0]:spans[header["DIGEST"]][1]]
+		if digestRowRE.MatchString("DIGEST: " + value) {
+			row.digest = strings.ToLower(value)
+		}
+		if text != "" && text != "NULL" {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// digestText normalizes a query the way performance_schema does for DIGEST_TEXT: literals
+// are replaced by ?, lists of values are collapsed and comments and quotes are removed.
+// It is also used to normalize DIGEST_TEXT values, so both give the same text. It is only
+// an approximation: queries that MySQL normalizes differently get different texts.
+func digestText(q string) string {
+	parts := []string{}
+	for _, tok := range tokenize(strings.TrimSpace(q)) {
+		switch tok.kind {
+		case tokenSpace, tokenComment:
+		case tokenString, tokenNumber, tokenPlaceholder:
+			parts = append(parts, "?")
+		case tokenWord:
+			text := strings.ToLower(tok.text)
+			// NULL is a value, except in IS NULL and IS NOT NULL
+			if text == "null" && (len(parts) == 0 || (parts[len(parts)-1] != "is" && parts[len(parts)-1] != "not")) {
+				text = "?"
+			}
+			parts = append(parts, text)
+		case tokenQuotedIdent:
+			parts = append(parts, strings.ToLower(unquote(tok.text)))
+		default:
+			parts = append(parts, strings.ToLower(tok.text))
+		}
+	}
+	if len(parts) > 0 && parts[len(parts)-1] == ";" {
+		parts = parts[:len(parts)-1]
+	}
+	text := digestListRE.ReplaceAllString(strings.Join(parts, " "), "(?+)")
+	return digestRowsRE.ReplaceAllString(text, "(?+)")
+}
+
+// columnSpans returns the start and end of each field of a line of a table separated by
+// sep, without the spaces around the fields and the table borders
+func columnSpans(line, sep string) [][2]int {
+	start, end := 0, len(line)
+	if sep == "|" {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "|") {
+			return nil
+		}
+		start = strings.Index(line, "|") + 1
+		if strings.HasSuffix(trimmed, "|") && strings.Count(trimmed, "|") > 1 {
+			end = strings.LastIndex(line, "|")
+		}
+	}
+	spans := [][2]int{}
+	for {
+		fieldEnd := end
+		if i := strings.Index(line[start:end], sep); i >= 0 {
+			fieldEnd = start + i
+		}
+		field := line[start:fieldEnd]
+		left := len(field) - len(strings.TrimLeft(field, " \t"))
+		right := len(strings.TrimRight(field, " \t"))
+		if right < left {
+			right = left
+		}
+		spans = append(spans, [2]int{start + left, start + right})
+		if fieldEnd == end {
+			return spans
+		}
+		start = fieldEnd + len(sep)
+	}
+}
+
+// splitColumns splits a line of a table using sep, removing the table borders
+func splitColumns(line, sep string) []string {
+	line = strings.TrimSpace(line)
+	if sep == "|" {
+		if !strings.HasPrefix(line, "|") {
+			return nil
+		}
+		line = strings.Trim(line, "|")
+	}
+	fields := strings.Split(line, sep)
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields
+}
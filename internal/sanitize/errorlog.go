@@ -0,0 +1,107 @@
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// The prefix of the error log messages: the time, the thread id and the level, and
+	// since MySQL 8.0, the error code and the subsystem, like in
+	// 2019-01-21T10:02:14.021437Z 8 [Warning] [MY-010055] [Server] IP address ...
+	errorLogPrefixRE = regexp.MustCompile(`^(\d{4}-\d\d-\d\d[T ]\d\d:\d\d:\d\d(?:\.\d+)?(?:Z|[+\-]\d\d:\d\d)?\s+(?:\d+\s+)?\[\w+\]\s+(?:\[MY-\d+\]\s+\[\w+\]\s+)?)(.*)$`)
+	// The prefix used by MySQL 5.5 and older, like 180305 13:24:55 [Note]
+	errorLogOldPrefixRE = regexp.MustCompile(`^(\d{6}\s+\d?\d:\d\d:\d\d\s+(?:\[\w+\]\s+)?)(.*)$`)
+
+	// 'bob'@'10.1.2.3', like in Access denied for user 'bob'@'10.1.2.3'
+	errorLogAccountRE = regexp.MustCompile(`'([^'@\s]*)'@'([^'\s]*)'`)
+	// Aborted connection 12 to db: 'shop' user: 'bob' host: 'app7.corp'
+	abortedDatabaseRE = regexp.MustCompile(`(\bdb: ')([^']*)(')`)
+	abortedUserRE     = regexp.MustCompile(`(\buser: ')([^']*)(')`)
+	abortedHostRE     = regexp.MustCompile(`(\bhost: ')([^']*)(')`)
+
+	// The query of a replication error, which can continue in the next lines, like in
+	// Query: 'INSERT INTO users (email)
+	// VALUES ('alice@example.com')', Error_code: MY-001062
+	errorQueryStartRE = regexp.MustCompile(`\bQuery: '`)
+	errorQueryEndRE   = regexp.MustCompile(`'(?:, Error_code: [\w\-]+)?\s*$`)
+)
+
+// sanitizeErrorLogFile sanitizes the MySQL error log, in the 5.5 to 8.0 formats. The users,
+// hosts and databases of the failed and aborted connections are aliased, and the queries
+// and duplicate keys of the replication errors are sanitized like in SHOW REPLICA STATUS.
+// The users are aliased by the hostnames and IPs rules too, so the user of a failed login
+// is not left next to its aliased host. The times, thread ids, levels and error codes are kept.
+// A query spanning several lines is sanitized as a whole into the first line, and its
+// other lines are left empty.
+func (s *Sanitizer) sanitizeErrorLogFile(lines []string) []string {
+	for i := 0; i < len(lines); i++ {
+		s.line++
+		m := errorLogPrefix(lines[i])
+		if m == nil {
+			lines[i] = s.sanitizeLine(lines[i])
+			continue
+		}
+		end := i + 1
+		if (s.opts.Queries || s.opts.Identifiers) && errorQueryStartRE.MatchString(m[2]) && !errorQueryEndRE.MatchString(m[2]) {
+			for end < len(lines) && end-i < maxQueryLines && errorLogPrefix(lines[end]) == nil {
+				end++
+				if errorQueryEndRE.MatchString(lines[end-1]) {
+					break
+				}
+			}
+		}
+		msg := strings.Join(append([]string{m[2]}, lines[i+1:end]...), "\n")
+		copy(lines[i:end], fitLines(strings.Split(m[1]+s.sanitizeErrorLogMessage(msg), "\n"), end-i))
+		s.line += end - i - 1
+		i = end - 1
+	}
+	return lines
+}
+
+// errorLogPrefix splits an error log line into its prefix and its message, or returns nil
+// if the line has no prefix
+func errorLogPrefix(line string) []string {
+	if m := errorLogPrefixRE.FindStringSubmatch(line); m != nil {
+		return m
+	}
+	return errorLogOldPrefixRE.FindStringSubmatch(line)
+}
+
+func (s *Sanitizer) sanitizeErrorLogMessage(msg string) string {
+	msg = errorLogAccountRE.ReplaceAllStringFunc(msg, func(match string) string {
+		m := errorLogAccountRE.FindStringSubmatch(match)
+		return "'" + s.aliasErrorLogUser(m[1]) + "'@'" + s.aliasEndpointHost(m[2]) + "'"
+	})
+	if strings.HasPrefix(msg, "Aborted connection") {
+		msg = replaceQuoted(abortedUserRE, msg, s.aliasErrorLogUser)
+		msg = replaceQuoted(abortedHostRE, msg, s.aliasEndpointHost)
+		msg = replaceQuoted(abortedDatabaseRE, msg, func(db string) string {
+			if db == "unconnected" {
+				return db
+			}
+			return s.aliasDatabase(db)
+		})
+	}
+	return s.sanitizeReplicaError(msg)
+}
+
+// aliasErrorLogUser aliases the user of a connection if the users, hostnames or IPs rule
+// is enabled
+func (s *Sanitizer) aliasErrorLogUser(user string) string {
+	if s.opts.Users || (!s.opts.Hostnames && !s.opts.IPs) {
+		return s.aliasAccountUser(user)
+	}
+	if user == "" || isSystemUser(user) || s.aliases.IsAlias(user) {
+		return user
+	}
+	return s.replace(RuleUsers, user, s.alias(KindUser, user))
+}
+
+// replaceQuoted replaces the quoted value matched by the second group of re by fn(value)
+func replaceQuoted(re *regexp.Regexp, text string, fn func(string) string) string {
+	return re.ReplaceAllStringFunc(text, func(match string) string {
+		m := re.FindStringSubmatch(match)
+		return m[1] + fn(m[2]) + m[3]
+	})
+}
@@ -0,0 +1,191 @@
+package sanitize
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// File types detected by Classify
+const (
+	FileGeneric      = "generic"
+	FileProcesslist  = "processlist"
+	FileInnoDBStatus = "innodb-status"
+	FileVariables    = "variables"
+	FileMySQLAdmin   = "mysqladmin"
+	FilePS           = "ps"
+	FileNetstat      = "netstat"
+	FileLsof         = "lsof"
+	FileDf           = "df"
+	FileHostname     = "hostname"
+	FileSlaveStatus  = "slave-status"
+	FileSlowLog      = "slow-log"
+	FileMounts       = "mounts"
+	FileSummary      = "summary"
+	FileErrorLog     = "error-log"
+	FileGeneralLog   = "general-log"
+	FileBinlog       = "binlog"
+)
+
+// FileTypes lists the file types
+var FileTypes = []string{
+	FileGeneric, FileProcesslist, FileInnoDBStatus, FileVariables, FileMySQLAdmin, FilePS, FileNetstat, FileLsof,
+	FileDf, FileHostname, FileSlaveStatus, FileSlowLog, FileMounts,
+	FileSummary, FileErrorLog, FileGeneralLog, FileBinlog,
+}
+
+// How Classify detected the type of a file
+const (
+	ByName    = "detected by file name"
+	ByContent = "detected by content"
+	ByDefault = "unknown type"
+)
+
+// Number of lines read by Classify to detect the type of a file by its content
+const sniffLines = 50
+
+var (
+	// pt-stalk file name suffixes. Numbered suffixes, like -innodbstatus1, have their
+	// numbers removed before the lookup.
+	fileSuffixes = map[string]string{
+		"processlist":  FileProcesslist,
+		"innodbstatus": FileInnoDBStatus,
+		"variables":    FileVariables,
+		"mysqladmin":   FileMySQLAdmin,
+		"ps":           FilePS,
+		"netstat":      FileNetstat,
+		"lsof":         FileLsof,
+		"df":           FileDf,
+		"hostname":     FileHostname,
+		"slave-status": FileSlaveStatus,
+		"mounts":       FileMounts,
+		"mount":        FileMounts,
+		"log_error":    FileErrorLog,
+	}
+	slowLogNameRE = regexp.MustCompile(`(?i)slow.*\.log$`)
+	// Error logs, like mysqld.log, db-prod-07.err or error.log
+	errorLogNameRE = regexp.MustCompile(`(?i)(\.err|error.*\.log|^mysqld\.log)$`)
+	// General query logs, like general.log or mysql-general.log. The default name, the
+	// host name with the .log extension, is detected by content.
+	generalLogNameRE = regexp.MustCompile(`(?i)general.*\.log$`)
+	// pt-summary and pt-mysql-summary outputs, like pt-summary_2018-03-05_13_24_55.out
+	summaryNameRE = regexp.MustCompile(`^pt-(mysql-)?summary`)
+
+	// Content signatures, checked in order. A file matching the first regexp of a
+	// signature has its type.
+	fileSignatures = []struct {
+		fileType string
+		re       *regexp.Regexp
+	}{
+		{FileBinlog, regexp.MustCompile(`^#\d{6}\s+\d?\d:\d\d:\d\d\s+server id \d+\s+end_log_pos `)},
+		// Before the InnoDB status, that can be written into the error log
+		{FileErrorLog, regexp.MustCompile(`^(\d{4}-\d\d-\d\d[T ]\d\d:\d\d:\d\d\S*\s+(\d+\s+)?|\d{6}\s+\d?\d:\d\d:\d\d\s+)\[(Note|Warning|ERROR|System)\] `)},
+		{FileInnoDBStatus, regexp.MustCompile(`INNODB MONITOR OUTPUT`)},
+		{FileSummary, regexp.MustCompile(`^# Percona Toolkit (System|MySQL) Summary Report`)},
+		{FileSlaveStatus, regexp.MustCompile(`^\s*(Slave|Replica)_IO_State:`)},
+		{FileProcesslist, regexp.MustCompile(`^\s*Command: `)},
+		{FileGeneralLog, regexp.MustCompile(`^(\d{4}-\d\d-\d\dT\S+|\d{6}\s+\d?\d:\d\d:\d\d)?\s+\d+ (Connect|Query|Init DB|Quit)\t`)},
+		{FileSlowLog, regexp.MustCompile(`^# (Time|User@Host|Query_time): `)},
+		{FileMySQLAdmin, regexp.MustCompile(`^\|\s*(Aborted_clients|Uptime|Com_select)\s*\|`)},
+		{FileVariables, regexp.MustCompile(`^(\|?\s*Variable_name\s*(\t|\|)\s*Value|\s*Variable_name: )`)},
+		{FilePS, regexp.MustCompile(`^\s*(UID|USER)\s+PID\s+`)},
+		{FileNetstat, regexp.MustCompile(`^(Active Internet connections|Proto\s+Recv-Q\s+Send-Q)`)},
+		{FileLsof, regexp.MustCompile(`^COMMAND\s+PID\s+.*USER\s+FD\s+TYPE`)},
+		{FileDf, regexp.MustCompile(`^Filesystem\s+.*Mounted on`)},
+		{FileMounts, regexp.MustCompile(`^\S+ on /\S* type \S+ \(`)},
+	}
+
+	// fileSanitizers are the sanitizers of the file types that need more than the generic
+	// line by line sanitization. The other types use Sanitize.
+	fileSanitizers = map[string]func(*Sanitizer, []string) []string{
+		FileHostname:    (*Sanitizer).sanitizeHostnameFile,
+		FilePS:          (*Sanitizer).sanitizePSFile,
+		FileNetstat:     (*Sanitizer).sanitizeOSFile,
+		FileLsof:        (*Sanitizer).sanitizeOSFile,
+		FileDf:          (*Sanitizer).sanitizeOSFile,
+		FileMounts:      (*Sanitizer).sanitizeOSFile,
+		FileVariables:   (*Sanitizer).sanitizeVariablesFile,
+		FileSlaveStatus: (*Sanitizer).sanitizeReplicaStatusFile,
+		FileErrorLog:    (*Sanitizer).sanitizeErrorLogFile,
+		FileGeneralLog:  (*Sanitizer).sanitizeGeneralLogFile,
+		FileBinlog:      (*Sanitizer).sanitizeBinlogFile,
+	}
+)
+
+// Classify returns the type of a file, detected by its name or, if the name is unknown,
+// by its first lines, and how the type was detected.
+func Classify(name string, lines []string) (string, string) {
+	if fileType := classifyName(name); fileType != "" {
+		return fileType, ByName
+	}
+	if len(lines) > sniffLines {
+		lines = lines[:sniffLines]
+	}
+	for _, signature := range fileSignatures {
+		for _, line := range lines {
+			if signature.re.MatchString(line) {
+				return signature.fileType, ByContent
+			}
+		}
+	}
+	return FileGeneric, ByDefault
+}
+
+func classifyName(name string) string {
+	base := strings.TrimRight(path.Base(name), "0123456789")
+	for suffix, fileType := range fileSuffixes {
+		if strings.HasSuffix(base, "-"+suffix) || base == suffix {
+			return fileType
+		}
+	}
+	if slowLogNameRE.MatchString(base) {
+		return FileSlowLog
+	}
+	if errorLogNameRE.MatchString(base) {
+		return FileErrorLog
+	}
+	if generalLogNameRE.MatchString(base) {
+		return FileGeneralLog
+	}
+	if summaryNameRE.MatchString(base) {
+		return FileSummary
+	}
+	return ""
+}
+
+// HasFileSanitizer returns true if the file type has a format specific sanitizer instead
+// of the generic one
+func HasFileSanitizer(fileType string) bool {
+	_, ok := fileSanitizers[fileType]
+	return ok
+}
+
+// SanitizeFile sanitizes the lines of a file of the given type using its format specific
+// sanitizer. Files without one, and generic files, are sanitized by Sanitize.
+func (s *Sanitizer) SanitizeFile(fileType string, lines []string) []string {
+	sanitizeFile, ok := fileSanitizers[fileType]
+	if !ok {
+		return s.Sanitize(lines)
+	}
+	s.line = 0
+	s.inCreateTable = false
+	s.inPrivateKey = false
+	s.inJoinedQuery = false
+	s.userTable = userTable{}
+	return sanitizeFile(s, lines)
+}
+
+// sanitizeHostnameFile aliases the host names in the output of the hostname command.
+// They are aliased even if they don't look like host names, like db-prod-07.
+func (s *Sanitizer) sanitizeHostnameFile(lines []string) []string {
+	for i, line := range lines {
+		s.line++
+		name := strings.TrimSpace(line)
+		if !s.opts.Hostnames || name == "" || strings.ContainsAny(name, " \t") {
+			lines[i] = s.sanitizeLine(line)
+			continue
+		}
+		lines[i] = strings.Replace(line, name, s.replace(RuleHostnames, name, s.alias(KindHost, name)), 1)
+	}
+	return lines
+}
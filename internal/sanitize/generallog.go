@@ -0,0 +1,82 @@
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// The header of a general log event: the time, only in the first event of each second
+	// before MySQL 5.7, the thread id and the command, like in
+	// 2018-02-05T02:46:43.015898Z	    3 Query	select 1
+	generalLogEventRE = regexp.MustCompile(`^((?:\d{4}-\d\d-\d\dT\S+|\d{6}\s+\d?\d:\d\d:\d\d)?\s+\d+ ([A-Z][a-z]+(?: [A-Za-z]+)?)\t)(.*)$`)
+	// The lines written when the server starts or the log is flushed
+	generalLogPreambleRE = regexp.MustCompile(`^(\S.*, Version: .*started with:|Tcp port: \d+|Time\s+Id\s+Command\s+Argument)`)
+	// user@host on db using TCP/IP, the argument of Connect and Change user. Users
+	// connected by a proxy user are shown as user@host as proxy.
+	generalLogConnectRE = regexp.MustCompile(`^([^@\s]*)@(\S*)( as \S+)? on (\S*)(.*)$`)
+)
+
+// sanitizeGeneralLogFile sanitizes the general query log. The arguments of the Query,
+// Execute and Prepare events are sanitized as queries, whatever their statement, and the
+// users, hosts and databases of the connections are aliased. An event ends where the next
+// one starts, so queries without a trailing semicolon or spanning several lines are
+// sanitized as a whole.
+func (s *Sanitizer) sanitizeGeneralLogFile(lines []string) []string {
+	sanitized := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); {
+		m := generalLogEventRE.FindStringSubmatch(lines[i])
+		if m == nil {
+			s.line++
+			sanitized = append(sanitized, s.sanitizeLine(lines[i]))
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && !generalLogEventRE.MatchString(lines[end]) && !generalLogPreambleRE.MatchString(lines[end]) {
+			end++
+		}
+		s.line++
+		argument := strings.Join(append([]string{m[3]}, lines[i+1:end]...), "\n")
+		sanitized = append(sanitized, m[1]+s.sanitizeGeneralLogArgument(m[2], argument))
+		s.line += end - i - 1
+		i = end
+	}
+	return sanitized
+}
+
+func (s *Sanitizer) sanitizeGeneralLogArgument(command, argument string) string {
+	switch command {
+	case "Query", "Execute", "Prepare":
+		if s.opts.Queries || s.opts.Identifiers {
+			return s.sanitizeQuery(argument)
+		}
+	case "Connect", "Change user":
+		m := generalLogConnectRE.FindStringSubmatch(argument)
+		if m == nil {
+			// Failed connections show the error, like Access denied for user 'bob'@'host'
+			return s.sanitizeErrorLogMessage(argument)
+		}
+		return s.aliasAccountUser(m[1]) + "@" + s.aliasEndpointHost(m[2]) + m[3] + " on " + s.aliasDatabase(m[4]) + m[5]
+	case "Init DB":
+		return s.aliasDatabase(argument)
+	case "Field List":
+		if s.opts.Identifiers && argument != "" {
+			return s.replace(RuleIdentifiers, argument, s.alias(KindTable, argument))
+		}
+	}
+	physical := strings.Split(argument, "\n")
+	for i := range physical {
+		physical[i] = s.sanitizeLine(physical[i])
+	}
+	return strings.Join(physical, "\n")
+}
+
+// aliasDatabase aliases a database name if identifiers are sanitized. System schemas are
+// kept.
+func (s *Sanitizer) aliasDatabase(db string) string {
+	if !s.opts.Identifiers || db == "" || systemSchemas[strings.ToLower(db)] {
+		return db
+	}
+	return s.replace(RuleIdentifiers, db, s.alias(KindDatabase, db))
+}
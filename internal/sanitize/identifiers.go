@@ -0,0 +1,373 @@
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+)
+
+type identContext int
+
+const (
+	ctxNone identContext = iota
+	ctxTable
+	ctxAfterTable
+	ctxTableAlias
+	ctxDatabase
+	ctxIndex
+	ctxIndexList
+	ctxColumnAlias
+	ctxValue
+)
+
+var (
+	// System schemas (and everything inside them) are never obfuscated
+	systemSchemas = map[string]bool{
+		"mysql":              true,
+		"information_schema": true,
+		"performance_schema": true,
+		"sys":                true,
+	}
+
+	processlistDbRe  = regexp.MustCompile(`^(\s*db: )(\S+)\s*$`)
+	useDbRe          = regexp.MustCompile("(?i)^(use )(`?[^`;\\s]+`?)(;?)$")
+	slowLogSchemaRe  = regexp.MustCompile(`^(# Schema: )(\S+)`)
+	quotedTableRe    = regexp.MustCompile("`([^`]+)`\\.`([^`]+)`")
+	innodbIndexRe    = regexp.MustCompile("(index )(`?[^`\\s]+`?)( of table )")
+	createTableOpen  = regexp.MustCompile(`(?i)create\s+(temporary\s+)?table\b.*\(\s*$`)
+	createTableClose = regexp.MustCompile(`^\s*\)`)
+
+	identKeywords = map[string]bool{}
+)
+
+func init() {
+	keywords := []string{
+		// Statements and clauses
+		"ACCESSIBLE", "ACTION", "ADD", "AFTER", "AGAINST", "ALGORITHM", "ALL", "ALTER", "ANALYZE", "AND", "ANY", "AS",
+		"ASC", "ASCII", "AUTO_INCREMENT", "AVG_ROW_LENGTH", "BEFORE", "BEGIN", "BETWEEN", "BINARY", "BOTH", "BTREE", "BY",
+		"CALL", "CASCADE", "CASE", "CHANGE", "CHARACTER", "CHARSET", "CHECK", "CHECKSUM", "COLLATE", "COLUMN", "COLUMNS",
+		"COMMENT", "COMMIT", "COMPACT", "COMPRESSED", "CONSTRAINT", "CREATE", "CROSS", "CURRENT_DATE", "CURRENT_TIME",
+		"CURRENT_TIMESTAMP", "CURRENT_USER", "DATABASE", "DATABASES", "DEALLOCATE", "DEFAULT", "DEFINER", "DELAYED",
+		"DELAY_KEY_WRITE", "DELETE", "DESC", "DESCRIBE", "DETERMINISTIC", "DISTINCT", "DISTINCTROW", "DIV", "DO", "DROP",
+		"DUAL", "DUPLICATE", "DYNAMIC", "EACH", "ELSE", "ELSEIF", "END", "ENGINE", "ENGINES", "ESCAPE", "EVENT", "EXECUTE",
+		"EXISTS", "EXPLAIN", "EXTENDED", "FALSE", "FIELDS", "FIRST", "FIXED", "FOR", "FORCE", "FOREIGN", "FORMAT", "FROM",
+		"FULL", "FULLTEXT", "FUNCTION", "GLOBAL", "GRANT", "GROUP", "HANDLER", "HASH", "HAVING", "HIGH_PRIORITY", "IF",
+		"IGNORE", "IN", "INDEX", "INDEXES", "INNER", "INSERT", "INTERVAL", "INTO", "INVOKER", "IS", "JOIN", "KEY",
+		"KEYS", "KEY_BLOCK_SIZE", "KILL", "LAST", "LEADING", "LEFT", "LIKE", "LIMIT", "LOAD", "LOCAL", "LOCALTIME",
+		"LOCALTIMESTAMP", "LOCK", "LOW_PRIORITY", "MATCH", "MAX_ROWS", "MIN_ROWS", "MOD", "MODE", "MODIFY", "NAMES",
+		"NATURAL", "NO", "NOT", "NULL", "OF", "OFFSET", "ON", "OPTIMIZE", "OR", "ORDER", "OUTER", "OUTFILE", "PACK_KEYS",
+		"PARTITION", "PARTITIONS", "PREPARE", "PRIMARY", "PROCEDURE", "PROCESSLIST", "QUICK", "RANGE", "READ",
+		"REDUNDANT", "REFERENCES", "REGEXP", "RELEASE", "RENAME", "REPAIR", "REPLACE", "RESTRICT", "RETURN", "RETURNS",
+		"REVOKE", "RIGHT", "RLIKE", "ROLLBACK", "ROLLUP", "ROW_FORMAT", "SAVEPOINT", "SCHEMA", "SCHEMAS", "SECURITY",
+		"SELECT", "SEPARATOR", "SESSION", "SET", "SHARE", "SHOW", "SOUNDS", "SPATIAL", "SQL", "SQL_BIG_RESULT",
+		"SQL_BUFFER_RESULT", "SQL_CACHE", "SQL_CALC_FOUND_ROWS", "SQL_NO_CACHE", "SQL_SMALL_RESULT", "START", "STARTING",
+		"STATS_AUTO_RECALC", "STATS_PERSISTENT", "STORED", "STRAIGHT_JOIN", "TABLE", "TABLES", "TABLESPACE",
+		"TEMPORARY", "TERMINATED", "THEN", "TO", "TRAILING", "TRANSACTION", "TRIGGER", "TRIGGERS", "TRUE", "TRUNCATE",
+		"UNDEFINED", "UNION", "UNIQUE", "UNLOCK", "UNSIGNED", "UPDATE", "USE", "USING", "VALUE", "VALUES", "VIEW",
+		"VIRTUAL", "WHEN", "WHERE", "WITH", "WORK", "WRITE", "XOR", "ZEROFILL",
+		// Interval units
+		"MICROSECOND", "SECOND", "MINUTE", "HOUR", "DAY", "WEEK", "MONTH", "QUARTER", "YEAR", "SECOND_MICROSECOND",
+		"MINUTE_MICROSECOND", "MINUTE_SECOND", "HOUR_MICROSECOND", "HOUR_SECOND", "HOUR_MINUTE", "DAY_MICROSECOND",
+		"DAY_SECOND", "DAY_MINUTE", "DAY_HOUR", "YEAR_MONTH",
+		// Data types
+		"BIGINT", "BIT", "BLOB", "BOOL", "BOOLEAN", "CHAR", "DATE", "DATETIME", "DEC", "DECIMAL", "DOUBLE", "ENUM", "FLOAT",
+		"GEOMETRY", "INT", "INTEGER", "JSON", "LONGBLOB", "LONGTEXT", "MEDIUMBLOB", "MEDIUMINT", "MEDIUMTEXT", "NUMERIC",
+		"POINT", "PRECISION", "REAL", "SERIAL", "SMALLINT", "TEXT", "TIME", "TIMESTAMP", "TINYBLOB", "TINYINT",
+		"TINYTEXT", "VARBINARY", "VARCHAR",
+	}
+	for _, kw := range keywords {
+		identKeywords[kw] = true
+	}
+}
+
+// identRef is an identifier of a query, maybe a qualified one like db.table.column
+type identRef struct {
+	// parts are the positions of the names in the tokens and kinds their alias kinds
+	parts []int
+	kinds []string
+	// column is true for the names found where columns are expected
+	column bool
+}
+
+// obfuscateIdentifiers replaces database, table, column and index names in a query by
+// their aliases. Keywords, functions, literals and variables are left unaltered. Table
+// aliases get their own aliases, and the columns of queries that only read system schemas,
+// like SELECT user, host FROM mysql.user, are kept.
+func (s *Sanitizer) obfuscateIdentifiers(q string) string {
+	tokens := tokenize(q)
+	refs, tableAliases, systemOnly := findIdentifiers(tokens)
+	for _, ref := range refs {
+		if systemOnly && (ref.column || ref.kinds[0] == KindTableAlias) {
+			continue
+		}
+		for j, p := range ref.parts {
+			if tokens[p].text == "*" {
+				continue
+			}
+			name := unquote(tokens[p].text)
+			kind := ref.kinds[j]
+			if kind == KindTable && j < len(ref.parts)-1 && tableAliases[strings.ToLower(name)] {
+				// The qualifier of a column, like o in o.customer_id, is a table alias
+				kind = KindTableAlias
+			}
+			tokens[p].text = s.replace(RuleIdentifiers, name, s.alias(kind, name))
+		}
+	}
+	return joinTokens(tokens)
+}
+
+// findIdentifiers returns the identifiers of the query in tokens that must be aliased, the
+// names of the table aliases it defines and whether all the tables it references are in
+// system schemas
+func findIdentifiers(tokens []token) ([]identRef, map[string]bool, bool) {
+	refs := []identRef{}
+	tableAliases := map[string]bool{}
+	systemTables, userTables := 0, 0
+	stmt := ""
+	prevKw := ""
+	ctx := ctxNone
+	indexHint := false
+	// Whether each open parenthesis is a derived table, like FROM (SELECT ...) AS t
+	derivedTables := []bool{}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch tok.kind {
+		case tokenSpace, tokenComment:
+			continue
+		case tokenPunct:
+			switch tok.text {
+			case ",":
+				if ctx == ctxAfterTable || ctx == ctxTableAlias {
+					ctx = ctxTable
+				}
+			case "=", ".":
+			case "(":
+				derivedTables = append(derivedTables, ctx == ctxTable)
+				if ctx == ctxIndex && indexHint {
+					ctx = ctxIndexList
+				} else if ctx != ctxIndexList {
+					ctx = ctxNone
+				}
+			case ")":
+				ctx = ctxNone
+				if n := len(derivedTables); n > 0 {
+					if derivedTables[n-1] {
+						// The alias of the derived table can follow
+						ctx = ctxAfterTable
+					}
+					derivedTables = derivedTables[:n-1]
+				}
+			default:
+				if ctx != ctxIndexList {
+					ctx = ctxNone
+				}
+			}
+			continue
+		case tokenWord:
+			if kw := strings.ToUpper(tok.text); identKeywords[kw] {
+				if stmt == "" {
+					stmt = kw
+				}
+				ctx, indexHint = keywordContext(stmt, prevKw, kw, ctx)
+				prevKw = kw
+				continue
+			}
+		case tokenQuotedIdent:
+		default:
+			if ctx != ctxIndexList {
+				ctx = ctxNone
+			}
+			continue
+		}
+
+		// We have an identifier, maybe a qualified one like db.table.column
+		parts := []int{i}
+		last := i
+		for {
+			dot := nextSignificant(tokens, last)
+			if dot < 0 || tokens[dot].text != "." {
+				break
+			}
+			part := nextSignificant(tokens, dot)
+			if part < 0 || (tokens[part].kind != tokenWord && tokens[part].kind != tokenQuotedIdent && tokens[part].text != "*") {
+				break
+			}
+			parts = append(parts, part)
+			last = part
+		}
+		i = last
+		if stmt == "" {
+			stmt = "?"
+		}
+
+		next := nextSignificant(tokens, last)
+		isCall := next >= 0 && tokens[next].text == "(" && tok.kind == tokenWord && len(parts) == 1
+		if isCall && ctx != ctxTable && ctx != ctxIndex {
+			// function call
+			continue
+		}
+		if next >= 0 && tokens[next].text == "(" && len(parts) == 2 && ctx != ctxTable && ctx != ctxIndex {
+			// stored function call, like sys.format_bytes(): only the database is aliased
+			if !systemSchemas[strings.ToLower(unquote(tokens[parts[0]].text))] {
+				refs = append(refs, identRef{parts: parts[:1], kinds: []string{KindDatabase}})
+			}
+			continue
+		}
+		if (stmt == "SHOW" || stmt == "SET") && ctx != ctxTable && ctx != ctxDatabase {
+			continue
+		}
+
+		var kinds []string
+		column := false
+		switch ctx {
+		case ctxTable:
+			kinds = []string{KindDatabase, KindTable}
+			ctx = ctxAfterTable
+		case ctxAfterTable, ctxTableAlias:
+			kinds = []string{KindTableAlias}
+			ctx = ctxAfterTable
+			if len(parts) == 1 {
+				tableAliases[strings.ToLower(unquote(tokens[i].text))] = true
+			}
+		case ctxDatabase:
+			kinds = []string{KindDatabase}
+			ctx = ctxNone
+		case ctxIndex, ctxIndexList:
+			kinds = []string{KindIndex}
+			if ctx == ctxIndex {
+				ctx = ctxNone
+			}
+		case ctxValue:
+			ctx = ctxNone
+			continue
+		default:
+			kinds = []string{KindDatabase, KindTable, KindColumn}
+			column = true
+			ctx = ctxNone
+		}
+		if len(parts) > len(kinds) {
+			continue
+		}
+		kinds = kinds[len(kinds)-len(parts):]
+		system := kinds[0] == KindDatabase && systemSchemas[strings.ToLower(unquote(tokens[parts[0]].text))]
+		if kinds[len(kinds)-1] == KindTable {
+			if system {
+				systemTables++
+			} else {
+				userTables++
+			}
+		}
+		if system {
+			continue
+		}
+		refs = append(refs, identRef{parts: parts, kinds: kinds, column: column})
+	}
+	return refs, tableAliases, systemTables > 0 && userTables == 0
+}
+
+// keywordContext returns what kind of identifier is expected after the keyword kw
+func keywordContext(stmt, prevKw, kw string, ctx identContext) (identContext, bool) {
+	switch kw {
+	case "FROM", "IN":
+		if stmt == "SHOW" {
+			// SHOW COLUMNS FROM table [FROM db], SHOW TABLES FROM db
+			switch prevKw {
+			case "COLUMNS", "FIELDS", "INDEX", "INDEXES", "KEYS":
+				return ctxTable, false
+			}
+			return ctxDatabase, false
+		}
+		if kw == "IN" {
+			return ctxNone, false
+		}
+		return ctxTable, false
+	case "JOIN", "STRAIGHT_JOIN", "INTO", "UPDATE", "TABLE", "REFERENCES", "DESCRIBE", "EXPLAIN":
+		if kw == "UPDATE" && prevKw == "KEY" { // ON DUPLICATE KEY UPDATE
+			return ctxNone, false
+		}
+		return ctxTable, false
+	case "TRUNCATE":
+		return ctxTable, false
+	case "USE", "DATABASE", "SCHEMA":
+		return ctxDatabase, false
+	case "KEY", "INDEX", "CONSTRAINT":
+		hint := prevKw == "USE" || prevKw == "FORCE" || prevKw == "IGNORE"
+		return ctxIndex, hint
+	case "ON":
+		if stmt == "GRANT" || stmt == "REVOKE" || stmt == "CREATE" || stmt == "DROP" {
+			return ctxTable, false
+		}
+		return ctxNone, false
+	case "AS":
+		if ctx == ctxAfterTable {
+			return ctxTableAlias, false
+		}
+		return ctxColumnAlias, false
+	case "CHARSET", "COLLATE", "ENGINE", "ROW_FORMAT":
+		return ctxValue, false
+	case "SET":
+		if prevKw == "CHARACTER" {
+			return ctxValue, false
+		}
+	case "FOR":
+		if ctx == ctxIndex { // USE INDEX FOR JOIN (...)
+			return ctxIndex, true
+		}
+	case "EXISTS", "NOT", "IF", "TEMPORARY", "IGNORE", "LOW_PRIORITY", "DELAYED", "HIGH_PRIORITY", "QUICK":
+		// Modifiers that don't change the expected identifier: CREATE TABLE IF NOT EXISTS t
+		if ctx == ctxTable || ctx == ctxDatabase {
+			return ctx, false
+		}
+	}
+	return ctxNone, false
+}
+
+// obfuscateStructuredIdentifiers obfuscates identifiers found outside queries: the
+// processlist db column, USE statements in the slow log and `db`.`table` names in the
+// InnoDB status.
+func (s *Sanitizer) obfuscateStructuredIdentifiers(line string) string {
+	if m := processlistDbRe.FindStringSubmatch(line); m != nil {
+		if m[2] == "NULL" || systemSchemas[strings.ToLower(m[2])] {
+			return line
+		}
+		return m[1] + s.replace(RuleIdentifiers, m[2], s.alias(KindDatabase, m[2]))
+	}
+	if m := useDbRe.FindStringSubmatch(line); m != nil {
+		if systemSchemas[strings.ToLower(unquote(m[2]))] {
+			return line
+		}
+		name := unquote(m[2])
+		return m[1] + s.replace(RuleIdentifiers, name, s.alias(KindDatabase, name)) + m[3]
+	}
+	line = slowLogSchemaRe.ReplaceAllStringFunc(line, func(match string) string {
+		m := slowLogSchemaRe.FindStringSubmatch(match)
+		if systemSchemas[strings.ToLower(m[2])] {
+			return match
+		}
+		return m[1] + s.replace(RuleIdentifiers, m[2], s.alias(KindDatabase, m[2]))
+	})
+	line = innodbIndexRe.ReplaceAllStringFunc(line, func(match string) string {
+		m := innodbIndexRe.FindStringSubmatch(match)
+		name := unquote(m[2])
+		if name == "PRIMARY" || name == "GEN_CLUST_INDEX" {
+			return match
+		}
+		return m[1] + s.replace(RuleIdentifiers, name, s.alias(KindIndex, name)) + m[3]
+	})
+	return quotedTableRe.ReplaceAllStringFunc(line, func(match string) string {
+		m := quotedTableRe.FindStringSubmatch(match)
+		if systemSchemas[strings.ToLower(m[1])] {
+			return match
+		}
+		return s.replace(RuleIdentifiers, match, s.alias(KindDatabase, m[1])+"."+s.alias(KindTable, m[2]))
+	})
+}
+
+func nextSignificant(tokens []token, pos int) int {
+	for i := pos + 1; i < len(tokens); i++ {
+		if tokens[i].kind != tokenSpace && tokens[i].kind != tokenComment {
+			return i
+		}
+	}
+	return -1
+}
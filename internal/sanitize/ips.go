@@ -0,0 +1,23 @@
+package sanitize
+
+// sanitizeIPs replaces IPv4 addresses by aliases like ip-0001. Addresses that don't identify
+// a host, like 127.0.0.1, are kept. Dotted numbers with more than four parts, like some
+// version numbers, are not addresses.
+func (s *Sanitizer) sanitizeIPs(line string) string {
+	locs := ipRE.FindAllStringIndex(line, -1)
+	if locs == nil {
+		return line
+	}
+	sanitized := ""
+	last := 0
+	for _, loc := range locs {
+		ip := line[loc[0]:loc[1]]
+		if ignoredIPs[ip] || (loc[0] > 0 && line[loc[0]-1] == '.') ||
+			(loc[1]+1 < len(line) && line[loc[1]] == '.' && isDigit(line[loc[1]+1])) {
+			continue
+		}
+		sanitized += line[last:loc[0]] + s.replace(RuleIPs, ip, s.alias(KindIP, ip))
+		last = loc[1]
+	}
+	return sanitized + line[last:]
+}
@@ -0,0 +1,144 @@
+package sanitize
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	// --password secret and --password=secret in command lines
+	argvPasswordRE = regexp.MustCompile(`(?i)(\s--?(?:password|passwd|pass|pwd)(?:=|\s+))('[^']*'|"[^"]*"|[^\s'"\-]\S*|-\S+)`)
+	// -psecret, the short password option of the MySQL clients. Other programs use -p for
+	// other things, like the port, so it is only redacted in MySQL command lines.
+	argvShortPasswordRE = regexp.MustCompile(`(\s-p)(\S+)`)
+	mysqlCommandRE      = regexp.MustCompile(`(?:^|[\s/])(?:mysql|mariadb|pt-|xtrabackup|innobackupex|mydumper|myloader)[\w\-]*(?:\s|$)`)
+
+	// host:port and [ipv6]:port, where the port can be a service name like mysql
+	endpointRE = regexp.MustCompile(`^(.+):([A-Za-z0-9_\-]+|\*)$`)
+	// Network file systems, like nfs01:/export/mysql or //fileserver/share
+	remoteFSRE = regexp.MustCompile(`^([A-Za-z0-9.\-\[\]:]+):(/.*)$`)
+	smbShareRE = regexp.MustCompile(`^//([^/]+)(/.*)?$`)
+	hostOnlyRE = regexp.MustCompile(`^[A-Za-z0-9.\-]*[A-Za-z][A-Za-z0-9.\-]*$`)
+)
+
+// sanitizePSFile redacts the passwords in the command lines of the ps output and aliases
+// the paths. PIDs, CPU and memory usage are kept.
+func (s *Sanitizer) sanitizePSFile(lines []string) []string {
+	for i, line := range lines {
+		s.line++
+		if s.opts.Credentials {
+			line = s.redactArgvPasswords(line)
+		}
+		lines[i] = s.sanitizeLine(line)
+	}
+	return lines
+}
+
+func (s *Sanitizer) redactArgvPasswords(line string) string {
+	redact := func(re *regexp.Regexp, line string) string {
+		return re.ReplaceAllStringFunc(line, func(match string) string {
+			m := re.FindStringSubmatch(match)
+			if isRedacted(strings.Trim(m[2], `"'`)) {
+				return match
+			}
+			return m[1] + s.replace(RuleCredentials, m[2], RedactedPassword)
+		})
+	}
+	line = redact(argvPasswordRE, line)
+	if mysqlCommandRE.MatchString(line) {
+		line = redact(argvShortPasswordRE, line)
+	}
+	return line
+}
+
+// sanitizeOSFile sanitizes the output of netstat, lsof, df and mount: remote endpoints and
+// network file system servers are aliased and so are the paths, while ports, sizes,
+// process names and states are kept.
+func (s *Sanitizer) sanitizeOSFile(lines []string) []string {
+	for i, line := range lines {
+		s.line++
+		lines[i] = s.sanitizeLine(replaceFields(line, s.sanitizeOSField))
+	}
+	return lines
+}
+
+func (s *Sanitizer) sanitizeOSField(field string) string {
+	if strings.Contains(field, "->") {
+		endpoints := strings.Split(field, "->")
+		for i := range endpoints {
+			endpoints[i] = s.sanitizeEndpoint(endpoints[i])
+		}
+		return strings.Join(endpoints, "->")
+	}
+	if strings.HasPrefix(field, "/") && !strings.HasPrefix(field, "//") {
+		return s.sanitizePath(field)
+	}
+	if m := smbShareRE.FindStringSubmatch(field); m != nil {
+		return "//" + s.aliasEndpointHost(m[1]) + s.sanitizePath(m[2])
+	}
+	if m := remoteFSRE.FindStringSubmatch(field); m != nil {
+		return s.aliasEndpointHost(m[1]) + ":" + s.sanitizePath(m[2])
+	}
+	return s.sanitizeEndpoint(field)
+}
+
+// sanitizeEndpoint aliases the host of host:port, keeping the port. Other values are
+// returned unchanged.
+func (s *Sanitizer) sanitizeEndpoint(endpoint string) string {
+	m := endpointRE.FindStringSubmatch(endpoint)
+	if m == nil {
+		return endpoint
+	}
+	return s.aliasEndpointHost(m[1]) + ":" + m[2]
+}
+
+// aliasEndpointHost aliases an IP address or host name. Local and wildcard addresses
+// are kept, and so are values that are neither, like times.
+func (s *Sanitizer) aliasEndpointHost(host string) string {
+	bare := strings.Trim(host, "[]")
+	if ip := net.ParseIP(bare); ip != nil {
+		if !s.opts.IPs || ignoredIPs[bare] || ip.IsLoopback() || ip.IsUnspecified() {
+			return host
+		}
+		return strings.Replace(host, bare, s.replace(RuleIPs, bare, s.alias(KindIP, bare)), 1)
+	}
+	if !s.opts.Hostnames || !hostOnlyRE.MatchString(host) || strings.EqualFold(host, "localhost") {
+		return host
+	}
+	return s.replace(RuleHostnames, host, s.alias(KindHost, host))
+}
+
+// replaceFields replaces each white space separated field of line by fn(field). The white
+// space after a replaced field is adjusted, when possible, so columns stay aligned.
+func replaceFields(line string, fn func(string) string) string {
+	var sb strings.Builder
+	// Number of characters the previous replacements added to the line
+	shift := 0
+	for i := 0; i < len(line); {
+		start := i
+		if isSpace(line[i]) {
+			for i < len(line) && isSpace(line[i]) {
+				i++
+			}
+			space := line[start:i]
+			if i < len(line) && line[start] == ' ' {
+				width := len(space) - shift
+				if width < 1 {
+					width = 1
+				}
+				shift -= len(space) - width
+				space = strings.Repeat(" ", width)
+			}
+			sb.WriteString(space)
+			continue
+		}
+		for i < len(line) && !isSpace(line[i]) {
+			i++
+		}
+		field := fn(line[start:i])
+		shift += len(field) - (i - start)
+		sb.WriteString(field)
+	}
+	return sb.String()
+}
@@ -0,0 +1,147 @@
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	// Absolute paths, preceded by the start of the line, a space, a quote or an option
+	// separator like in --datadir=/data/mysql
+	pathRE = regexp.MustCompile(`(^|[\s='"(,])(/[A-Za-z0-9._@+\-/]*)`)
+
+	// Directories containing the home directories of the users
+	homeRoots = map[string]bool{"home": true, "Users": true}
+	// Top level directories whose paths are kept: they only have system files and devices
+	systemRoots = map[string]bool{
+		"bin": true, "boot": true, "dev": true, "lib": true, "lib32": true, "lib64": true, "proc": true,
+		"sbin": true, "sys": true, "usr": true,
+	}
+	// Words of the directory and file names that say what they are for but not who they
+	// belong to, like mysql-files or mysql-bin.000012. Names are split in words by dashes,
+	// underscores and dots.
+	standardPathWords = map[string]bool{
+		// System directories
+		"bin": true, "boot": true, "cache": true, "conf": true, "config": true, "d": true, "default": true,
+		"defaults": true, "dev": true, "doc": true, "docs": true, "etc": true, "export": true, "home": true,
+		"include": true, "init": true, "lib": true, "libexec": true, "local": true, "lock": true, "man": true,
+		"media": true, "mnt": true, "nfs": true, "opt": true, "proc": true, "root": true, "run": true,
+		"sbin": true, "share": true, "spool": true, "src": true, "srv": true, "sys": true, "system": true,
+		"systemd": true, "temp": true, "tmp": true, "usr": true, "var": true,
+		// Security
+		"ca": true, "cert": true, "certs": true, "key": true, "keys": true, "pem": true, "pki": true,
+		"private": true, "public": true, "ssl": true, "tls": true,
+		// Generic data, log and backup directories
+		"archive": true, "backup": true, "backups": true, "data": true, "db": true, "dir": true,
+		"dump": true, "dumps": true, "files": true, "log": true, "logs": true, "new": true, "old": true,
+		// MySQL and its tools
+		"audit": true, "auto": true, "binlog": true, "binlogs": true, "buffer": true, "charsets": true,
+		"client": true, "cluster": true, "cnf": true, "community": true, "dblwr": true, "english": true,
+		"enterprise": true, "error": true, "general": true, "ib": true, "ibdata": true, "ibtmp": true,
+		"index": true, "info": true, "innodb": true, "keyring": true, "logfile": true, "mariadb": true,
+		"master": true, "my": true, "mylogin": true, "mysql": true, "mysqld": true, "mysqldata": true,
+		"mysqlrouter": true, "mysqlx": true, "percona": true, "performance": true, "pid": true, "plugin": true, "plugins": true,
+		"pool": true, "query": true, "redo": true, "relay": true, "relaylog": true, "relaylogs": true,
+		"replica": true, "router": true, "sandbox": true, "schema": true, "server": true, "slave": true, "slow": true,
+		"sock": true, "socket": true, "source": true, "toolkit": true, "undo": true, "xtrabackup": true,
+		"xtradb": true,
+		// Platforms
+		"aarch64": true, "amd64": true, "arm64": true, "glibc": true, "gnu": true, "linux": true,
+		"x86": true,
+		// Default users
+		"admin": true, "centos": true, "ec2": true, "ubuntu": true, "user": true, "vagrant": true,
+	}
+	pathWordSeparatorRE = regexp.MustCompile(`[\-_.]+`)
+	// Numbers and names of devices and releases, like 000012, sda1, el7 or v8
+	pathNumberRE = regexp.MustCompile(`^[A-Za-z]{0,3}\d+$`)
+	// File extensions, like .log, .sock or the sequence number of binary logs
+	pathExtensionRE = regexp.MustCompile(`^[A-Za-z0-9]{1,10}$`)
+)
+
+// sanitizePaths aliases the absolute paths found in text. See sanitizePath.
+func (s *Sanitizer) sanitizePaths(text string) string {
+	return s.sanitizePathsBefore(text, len(text))
+}
+
+// sanitizePathsBefore aliases the absolute paths starting before end, so divisions in the
+// queries after it are not taken as paths. A negative end means the whole text.
+func (s *Sanitizer) sanitizePathsBefore(text string, end int) string {
+	if !s.opts.Paths {
+		return text
+	}
+	if end < 0 {
+		end = len(text)
+	}
+	locs := pathRE.FindAllStringSubmatchIndex(text, -1)
+	if locs == nil {
+		return text
+	}
+	sanitized := ""
+	last := 0
+	for _, loc := range locs {
+		if loc[4] >= end {
+			break
+		}
+		sanitized += text[last:loc[4]] + s.sanitizePath(text[loc[4]:loc[5]])
+		last = loc[5]
+	}
+	return sanitized + text[last:]
+}
+
+// sanitizePath aliases the parts of a path that identify users, customers or projects: the
+// user name in home directories, like /home/karl, and the directory and file names that
+// are not standard, like /data/customers/acme. The extensions of the aliased file names
+// are kept, and so are the paths under system directories like /usr. Paths are not
+// changed if the paths rule is disabled.
+func (s *Sanitizer) sanitizePath(p string) string {
+	if !s.opts.Paths || !strings.HasPrefix(p, "/") {
+		return p
+	}
+	parts := strings.Split(p, "/")
+	if len(parts) < 3 || parts[2] == "" || systemRoots[parts[1]] {
+		return p
+	}
+	for i, part := range parts {
+		switch {
+		case part == "" || part == "." || part == ".." || s.aliases.IsAlias(part):
+		case i == 2 && homeRoots[parts[1]]:
+			if !isStandardPathName(part) {
+				parts[i] = s.replace(RulePaths, part, s.alias(KindUser, part))
+			}
+		case isStandardPathName(part):
+		case i == len(parts)-1:
+			parts[i] = s.aliasFileName(part)
+		default:
+			parts[i] = s.replace(RulePaths, part, s.alias(KindDir, part))
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// aliasFileName aliases a file name keeping its extension, like karl-slow.log. Names
+// without an extension are taken as directories.
+func (s *Sanitizer) aliasFileName(name string) string {
+	i := strings.LastIndex(name, ".")
+	if i <= 0 || !pathExtensionRE.MatchString(name[i+1:]) {
+		return s.replace(RulePaths, name, s.alias(KindDir, name))
+	}
+	stem := name[:i]
+	if isStandardPathName(stem) || s.aliases.IsAlias(stem) {
+		return name
+	}
+	return s.replace(RulePaths, stem, s.alias(KindFile, stem)) + name[i:]
+}
+
+// isStandardPathName returns true if all the words of a directory or file name are
+// standard words or numbers, like mysql-8.0.32-linux-glibc2.12-x86_64
+func isStandardPathName(name string) bool {
+	for _, word := range pathWordSeparatorRE.Split(name, -1) {
+		if word == "" || pathNumberRE.MatchString(word) {
+			continue
+		}
+		if !standardPathWords[strings.ToLower(strings.TrimRight(word, "0123456789"))] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package sanitize
+
+import "fmt"
+
+// Sanitization policies
+const (
+	// PolicyMinimal only redacts credentials and secrets
+	PolicyMinimal = "minimal"
+	// PolicyStandard also replaces queries by their fingerprints, keeping the optimizer
+	// hints and the sqlcommenter tags, and aliases host names and IP addresses
+	PolicyStandard = "standard"
+	// PolicyParanoid also aliases identifiers, MySQL user names, email addresses, server
+	// UUIDs and ids, and the user, customer and project names in paths, and drops the
+	// query text and its comments
+	PolicyParanoid = "paranoid"
+)
+
+// Policies lists the policies from the least to the most restrictive
+var Policies = []string{PolicyMinimal, PolicyStandard, PolicyParanoid}
+
+// Query modes
+const (
+	// QueryFingerprint replaces queries by their fingerprints
+	QueryFingerprint = "fingerprint"
+	// QueryDigest replaces queries by a hash of their digest text
+	QueryDigest = "digest"
+	// QueryShape replaces literals by their type and size, like ?int or ?str(32)
+	QueryShape = "shape"
+	// QueryDrop replaces queries by DroppedQuery
+	QueryDrop = "drop"
+)
+
+// QueryModes lists the valid values of Options.QueryMode
+var QueryModes = []string{QueryFingerprint, QueryShape, QueryDigest, QueryDrop}
+
+// DroppedQuery replaces queries when QueryMode is QueryDrop
+const DroppedQuery = "<query>"
+
+// PolicyOptions returns the options of a policy
+func PolicyOptions(policy string) (Options, error) {
+	opts := Options{
+		Credentials: true,
+		Secrets:     append([]string{}, SecretTypes...),
+	}
+	switch policy {
+	case PolicyMinimal:
+		return opts, nil
+	case PolicyStandard, PolicyParanoid:
+		opts.Queries = true
+		opts.QueryMode = QueryFingerprint
+		opts.Hostnames = true
+		opts.IPs = true
+		opts.KeepHints = true
+		opts.CommentKeys = append([]string{}, DefaultCommentKeys...)
+		if policy == PolicyParanoid {
+			opts.QueryMode = QueryDrop
+			opts.Identifiers = true
+			opts.Users = true
+			opts.Emails = true
+			opts.Paths = true
+			opts.UUIDs = true
+			opts.KeepHints = false
+			opts.CommentKeys = nil
+			opts.AliasCommentValues = true
+		}
+		return opts, nil
+	}
+	return Options{}, fmt.Errorf("Unknown sanitization policy %q", policy)
+}
+
+// Rules returns the names of the enabled rules
+func (o Options) Rules() []string {
+	rules := []string{}
+	add := func(enabled bool, rule string) {
+		if enabled {
+			rules = append(rules, rule)
+		}
+	}
+	add(o.Credentials, RuleCredentials)
+	add(o.Emails, RuleEmails)
+	add(o.Queries, RuleQueries)
+	add(o.Identifiers, RuleIdentifiers)
+	add(o.IPs, RuleIPs)
+	add(o.Hostnames, RuleHostnames)
+	add(o.Paths, RulePaths)
+	add(o.UUIDs, RuleUUIDs)
+	add(o.Users, RuleUsers)
+	return rules
+}
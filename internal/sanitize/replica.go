@@ -0,0 +1,198 @@
+package sanitize
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// How the value of a SHOW REPLICA STATUS field is sanitized
+type replicaFieldKind int
+
+const (
+	replicaGeneric replicaFieldKind = iota
+	replicaHost
+	replicaUser
+	replicaUUID
+	replicaServerID
+	replicaGTIDSet
+	replicaLogFile
+	replicaPath
+	replicaError
+	replicaDatabases
+	replicaTables
+)
+
+var (
+	// Fields of SHOW SLAVE STATUS and SHOW REPLICA STATUS, with their MySQL 8.0.22 names
+	replicaFields = map[string]replicaFieldKind{
+		"Master_Host":            replicaHost,
+		"Source_Host":            replicaHost,
+		"Master_Bind":            replicaHost,
+		"Source_Bind":            replicaHost,
+		"Master_User":            replicaUser,
+		"Source_User":            replicaUser,
+		"Master_UUID":            replicaUUID,
+		"Source_UUID":            replicaUUID,
+		"Master_Server_Id":       replicaServerID,
+		"Source_Server_Id":       replicaServerID,
+		"Retrieved_Gtid_Set":     replicaGTIDSet,
+		"Executed_Gtid_Set":      replicaGTIDSet,
+		"Master_Log_File":        replicaLogFile,
+		"Source_Log_File":        replicaLogFile,
+		"Relay_Log_File":         replicaLogFile,
+		"Relay_Master_Log_File":  replicaLogFile,
+		"Relay_Source_Log_File":  replicaLogFile,
+		"Master_Info_File":       replicaPath,
+		"Source_Info_File":       replicaPath,
+		"Master_SSL_CA_File":     replicaPath,
+		"Source_SSL_CA_File":     replicaPath,
+		"Master_SSL_CA_Path":     replicaPath,
+		"Source_SSL_CA_Path":     replicaPath,
+		"Master_SSL_Cert":        replicaPath,
+		"Source_SSL_Cert":        replicaPath,
+		"Master_SSL_Key":         replicaPath,
+		"Source_SSL_Key":         replicaPath,
+		"Master_SSL_Crl":         replicaPath,
+		"Source_SSL_Crl":         replicaPath,
+		"Master_SSL_Crlpath":     replicaPath,
+		"Source_SSL_Crlpath":     replicaPath,
+		"Last_Error":             replicaError,
+		"Last_IO_Error":          replicaError,
+		"Last_SQL_Error":         replicaError,
+		"Replicate_Do_DB":        replicaDatabases,
+		"Replicate_Ignore_DB":    replicaDatabases,
+		"Replicate_Do_Table":     replicaTables,
+		"Replicate_Ignore_Table": replicaTables,
+	}
+
+	replicaFieldRE = regexp.MustCompile(`^(\s*)(\w+): (.*)$`)
+	// Binary and relay log names, like db-prod-07-relay-bin.000012, where the prefix is
+	// the host name by default
+	logFileNameRE = regexp.MustCompile(`^(.+?)(-relay-bin|-bin)((?:-[\w\-]+)?\.(?:\d+|index))$`)
+	// Log name prefixes that are not host names
+	standardLogPrefixes = map[string]bool{"binlog": true, "mariadb": true, "mysql": true, "mysqld": true}
+
+	errorQueryRE          = regexp.MustCompile(`(?s)(Query: ')(.*)(')`)
+	errorDuplicateEntryRE = regexp.MustCompile(`(Duplicate entry ')(.*?)(' for key)`)
+	errorDatabaseRE       = regexp.MustCompile(`(Default database: ')([^']*)(')`)
+	errorAccountRE        = regexp.MustCompile(`'([^'@\s]+)@([^':\s]+)(:\d+)?'`)
+)
+
+// sanitizeReplicaStatusFile sanitizes the output of SHOW SLAVE STATUS\G and SHOW REPLICA
+// STATUS\G. Hosts, users and UUIDs get the same aliases used in the other files, the
+// queries in the error messages are sanitized like any other query, and the positions,
+// lag and thread states are kept.
+func (s *Sanitizer) sanitizeReplicaStatusFile(lines []string) []string {
+	inGTIDSet := false
+	for i, line := range lines {
+		s.line++
+		m := replicaFieldRE.FindStringSubmatch(line)
+		if m == nil {
+			// Long GTID sets continue in the next lines
+			if inGTIDSet {
+				lines[i] = s.aliasUUIDs(line)
+				continue
+			}
+			lines[i] = s.sanitizeLine(line)
+			continue
+		}
+		kind := replicaFields[m[2]]
+		inGTIDSet = kind == replicaGTIDSet
+		lines[i] = m[1] + m[2] + ": " + s.sanitizeReplicaField(kind, m[3])
+	}
+	return lines
+}
+
+func (s *Sanitizer) sanitizeReplicaField(kind replicaFieldKind, value string) string {
+	if value == "" {
+		return value
+	}
+	switch kind {
+	case replicaHost:
+		return s.aliasEndpointHost(value)
+	case replicaUser:
+		return s.aliasAccountUser(value)
+	case replicaUUID, replicaGTIDSet:
+		return s.aliasUUIDs(value)
+	case replicaServerID:
+		return s.aliasServerID(value)
+	case replicaLogFile:
+		return s.sanitizeLogFileName(value)
+	case replicaPath:
+		return s.sanitizePaths(value)
+	case replicaError:
+		return s.sanitizeReplicaError(value)
+	case replicaDatabases, replicaTables:
+		if !s.opts.Identifiers {
+			return value
+		}
+		kinds := []string{KindDatabase}
+		if kind == replicaTables {
+			kinds = []string{KindDatabase, KindTable}
+		}
+		names := strings.Split(value, ",")
+		for i, name := range names {
+			parts := strings.SplitN(name, ".", len(kinds))
+			if len(parts) != len(kinds) || systemSchemas[strings.ToLower(parts[0])] {
+				continue
+			}
+			for j, part := range parts {
+				parts[j] = s.replace(RuleIdentifiers, part, s.alias(kinds[j], part))
+			}
+			names[i] = strings.Join(parts, ".")
+		}
+		return strings.Join(names, ",")
+	}
+	return s.sanitizeLine(value)
+}
+
+// sanitizeLogFileName aliases the host name in a binary or relay log file name, like
+// db-prod-07-relay-bin.000012, keeping the sequence number
+func (s *Sanitizer) sanitizeLogFileName(name string) string {
+	dir, base := path.Split(name)
+	if dir != "" {
+		dir = s.sanitizePath(dir)
+	}
+	m := logFileNameRE.FindStringSubmatch(base)
+	if m == nil || !s.opts.Hostnames || standardLogPrefixes[strings.ToLower(m[1])] {
+		return dir + s.SanitizeFileName(base)
+	}
+	return dir + s.replace(RuleHostnames, m[1], s.alias(KindHost, m[1])) + m[2] + m[3]
+}
+
+// sanitizeReplicaError sanitizes the replication error messages. Queries are sanitized
+// like any other query, the values of duplicate keys are removed if queries are sanitized,
+// and accounts like 'repl@10.0.0.1:3306' are aliased.
+func (s *Sanitizer) sanitizeReplicaError(msg string) string {
+	if s.opts.Queries || s.opts.Identifiers {
+		if m := errorQueryRE.FindStringSubmatchIndex(msg); m != nil {
+			return s.sanitizeErrorText(msg[:m[3]]) + s.sanitizeQuery(msg[m[4]:m[5]]) + s.sanitizeErrorText(msg[m[6]:])
+		}
+	}
+	return s.sanitizeErrorText(msg)
+}
+
+// sanitizeErrorText sanitizes the parts of an error message that are not queries
+func (s *Sanitizer) sanitizeErrorText(msg string) string {
+	if s.opts.Queries {
+		msg = errorDuplicateEntryRE.ReplaceAllStringFunc(msg, func(match string) string {
+			m := errorDuplicateEntryRE.FindStringSubmatch(match)
+			return m[1] + s.replace(RuleQueries, m[2], "?") + m[3]
+		})
+	}
+	if s.opts.Identifiers {
+		msg = errorDatabaseRE.ReplaceAllStringFunc(msg, func(match string) string {
+			m := errorDatabaseRE.FindStringSubmatch(match)
+			if m[2] == "" || systemSchemas[strings.ToLower(m[2])] {
+				return match
+			}
+			return m[1] + s.replace(RuleIdentifiers, m[2], s.alias(KindDatabase, m[2])) + m[3]
+		})
+	}
+	msg = errorAccountRE.ReplaceAllStringFunc(msg, func(match string) string {
+		m := errorAccountRE.FindStringSubmatch(match)
+		return "'" + s.aliasAccountUser(m[1]) + "@" + s.aliasEndpointHost(m[2]) + m[3] + "'"
+	})
+	return s.sanitizeLine(msg)
+}
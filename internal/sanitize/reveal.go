@@ -0,0 +1,65 @@
+package sanitize
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Server ids are aliased by other numbers, so they are only revealed where a server id is
+// expected, like in "server id 17" or "Master_Server_Id: 17", and not in every number.
+var serverIDContextRE = regexp.MustCompile(`(?i)\b((?:master_|source_)?server[ _]id\b[\s:=|]*)(\d+)\b`)
+
+// Reveal replaces the aliases found in lines by their original values. mapping has the
+// same format as the one returned by Aliases.Mapping.
+func Reveal(lines []string, mapping map[string]map[string]string) []string {
+	originals := make(map[string]string)
+	for kind, aliases := range mapping {
+		if kind == KindServerID {
+			continue
+		}
+		for alias, value := range aliases {
+			originals[alias] = value
+		}
+	}
+	serverIDs := mapping[KindServerID]
+	if len(originals) == 0 && len(serverIDs) == 0 {
+		return lines
+	}
+
+	var aliasRe *regexp.Regexp
+	if len(originals) > 0 {
+		aliases := make([]string, 0, len(originals))
+		for alias := range originals {
+			aliases = append(aliases, regexp.QuoteMeta(alias))
+		}
+		// Longest aliases first so host-0001 is not revealed as host-000 + 1
+		sort.Slice(aliases, func(i, j int) bool {
+			if len(aliases[i]) != len(aliases[j]) {
+				return len(aliases[i]) > len(aliases[j])
+			}
+			return aliases[i] < aliases[j]
+		})
+		aliasRe = regexp.MustCompile(`\b(` + strings.Join(aliases, "|") + `)\b`)
+	}
+
+	revealed := make([]string, len(lines))
+	for i, line := range lines {
+		if len(serverIDs) > 0 {
+			line = serverIDContextRE.ReplaceAllStringFunc(line, func(match string) string {
+				m := serverIDContextRE.FindStringSubmatch(match)
+				if id, ok := serverIDs[m[2]]; ok {
+					return m[1] + id
+				}
+				return match
+			})
+		}
+		if aliasRe != nil {
+			line = aliasRe.ReplaceAllStringFunc(line, func(alias string) string {
+				return originals[alias]
+			})
+		}
+		revealed[i] = line
+	}
+	return revealed
+}
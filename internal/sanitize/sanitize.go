@@ -1,16 +1,51 @@
 package sanitize
 
 import (
+	"path"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/percona/go-mysql/query"
+	"golang.org/x/net/publicsuffix"
 )
 
 var (
-	hostnameRE    = regexp.MustCompile(`(([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]*[a-zA-Z0-9])\.)+([A-Za-z0-9]|[A-Za-z0-9][A-Za-z0-9\-][A-Za-z0-9]){2,3}(?:\W)`)
+	// Dotted names ending in a label that starts with a letter. isHostname decides if
+	// they are host names.
+	hostnameRE    = regexp.MustCompile(`\b(?:[A-Za-z0-9](?:[A-Za-z0-9\-]*[A-Za-z0-9])?\.)+[A-Za-z](?:[A-Za-z0-9\-]*[A-Za-z0-9])?\b`)
 	queryLineRe   []*regexp.Regexp
 	queryInLineRe []*regexp.Regexp
+
+	// Where a statement can start in a line: at its beginning, after the fields that hold
+	// queries, like Info: in the processlist, or in a tab separated column. Statements in
+	// the middle of other text, like "Last update from db1", are not SQL.
+	sqlFieldRe = `(?:^\s*|\b(?:Info|Query):\s*|#\s*Query:?\s+|\t\s*)`
+
+	// Lines that can continue a multi-line query: indented lines, lines starting with a
+	// clause keyword, a parenthesis, a comma or a quote, lines ending with a comma, like
+	// the ones of a column list, and the last line of the query
+	sqlContinuationRe = regexp.MustCompile("(?i)^(\\s+\\S|[(),'\"`]|(AND|OR|NOT|XOR|AS|FROM|WHERE|JOIN|INNER|LEFT|RIGHT|" +
+		"CROSS|STRAIGHT_JOIN|NATURAL|ON|USING|SET|VALUES?|SELECT|UNION|GROUP|ORDER|HAVING|LIMIT|OFFSET|FOR|LOCK|INTO|" +
+		"CASE|WHEN|THEN|ELSE|END|IN|BETWEEN|LIKE|IS|ASC|DESC|DUPLICATE|ENGINE|DEFAULT|PARTITION|ADD|MODIFY|CHANGE|" +
+		"DROP|ALGORITHM|LINES|FIELDS|CHARACTER)\\b|.*[,;]\\s*$)")
+	// Lines ending in a comma, an operator, an open parenthesis or a keyword that needs
+	// the next line of the query
+	sqlOpenEndRe = regexp.MustCompile(`(?i)([,(=<>+*/-]|\b(SELECT|FROM|WHERE|AND|OR|ON|SET|VALUES|BY|JOIN|IN|NOT|LIKE|AS|INTO|UNION|HAVING))\s*$`)
+	// Lines like "Master host: db2", that are not part of a query
+	fieldLineRe = regexp.MustCompile(`^\s*[A-Za-z_][\w ]*:\s`)
+
+	// File extensions that are also top level domains
+	fileExtensions = map[string]bool{
+		"am": true, "cc": true, "cs": true, "go": true, "in": true, "js": true, "md": true,
+		"pl": true, "pm": true, "py": true, "rb": true, "rs": true, "sh": true, "so": true,
+	}
+	// Generic top level domains older than the new gTLDs, like actor or city, that are also
+	// common table and column names
+	legacyTLDs = map[string]bool{
+		"biz": true, "com": true, "edu": true, "gov": true, "info": true, "int": true, "mil": true,
+		"net": true, "org": true,
+	}
 )
 
 func init() {
@@ -19,86 +54,519 @@ func init() {
 		"DROP (DATABASE|TABLE|VIEW|DEFINER)",
 		"INSERT INTO",
 		"REPLACE INTO",
-		"UPDATE",
+		"UPDATE\\s+(LOW_PRIORITY\\s+|IGNORE\\s+)*[\\w$.`]+(\\s+(AS\\s+)?\\w+)?\\s*(SET\\b|((INNER|LEFT|CROSS)\\s+|STRAIGHT_)?JOIN\\b|,|$)",
 		"SELECT.*FROM.*",
-		"SET ",
+		"SET\\s+(@@?\\w|(GLOBAL|SESSION|PERSIST|PERSIST_ONLY|LOCAL|NAMES|CHARACTER\\s+SET|CHARSET|TRANSACTION|PASSWORD|DEFAULT\\s+ROLE|ROLE)\\b|[\\w$.`]+\\s*:?=)",
 		"SHOW TABLES",
 		"SHOW DATABASES",
-		"COMMIT",
+		"COMMIT(\\s+WORK)?\\s*(;|$|\\s+AND\\b)",
 		"LOAD DATA",
+		"ALTER TABLE",
+		"DELETE FROM",
+		"TRUNCATE\\s+(TABLE\\s+)?[\\w$.`]+\\s*(;|$)",
+		"(ANALYZE|CHECK|CHECKSUM|OPTIMIZE|REPAIR) TABLE",
 	}
 	for _, re := range statements {
 		queryLineRe = append(queryLineRe, regexp.MustCompile("(?i)^"+re))
-		queryInLineRe = append(queryInLineRe, regexp.MustCompile(("(?im)(" + re + ".*)")))
+		queryInLineRe = append(queryInLineRe, regexp.MustCompile("(?im)"+sqlFieldRe+"("+re+".*)"))
+	}
+}
+
+// Rule names used in the matches report
+const (
+	RuleHostnames   = "hostnames"
+	RuleQueries     = "queries"
+	RuleIdentifiers = "identifiers"
+	RuleCredentials = "credentials"
+	RuleEmails      = "emails"
+	RuleIPs         = "ips"
+	RulePaths       = "paths"
+	RuleUUIDs       = "uuids"
+	RuleUsers       = "users"
+)
+
+// Options selects the sanitization rules to apply
+type Options struct {
+	Hostnames bool
+	Queries   bool
+	// QueryMode is how the queries rule replaces queries. The default is QueryFingerprint.
+	QueryMode string
+	IPs       bool
+	// Paths aliases the user names in home directories and the non-standard directory
+	// and file names in paths, keeping the file extensions
+	Paths bool
+	// UUIDs replaces server UUIDs, also in GTID sets, by fake UUIDs keeping the
+	// transaction ranges, and server ids by other numbers
+	UUIDs bool
+	// Users aliases the user names of the MySQL accounts, keeping the system users like
+	// root or event_scheduler
+	Users bool
+	// Identifiers replaces database, table and column names by aliases like db1.t7.c3
+	Identifiers bool
+	// Credentials redacts passwords and aliases users and hosts in URLs and DSNs
+	Credentials bool
+	Emails      bool
+	// Secrets is the list of secret types to redact. See SecretTypes.
+	Secrets []string
+	// KnownHosts are names of the server, like db-prod-07, that are aliased wherever they
+	// appear, even if they don't look like host names. Requires Hostnames.
+	KnownHosts []string
+	// InternalSuffixes are domain suffixes, like corp or int.example.com, that are not in
+	// the public suffix list but are used for host names. See DefaultInternalSuffixes.
+	InternalSuffixes []string
+	// Keep are values that are never replaced by any rule. A pattern can use shell globs,
+	// like *.cdn.example.com, and a domain also matches its subdomains.
+	Keep []string
+	// KeepHints keeps the /*+ ... */ optimizer hints of the sanitized queries
+	KeepHints bool
+	// CommentKeys are the keys of the sqlcommenter or marginalia tags, like controller,
+	// kept in the comments of the sanitized queries. All the other comments are removed.
+	CommentKeys []string
+	// AliasCommentValues replaces the values of the kept tags by aliases
+	AliasCommentValues bool
+}
+
+// maxQueryLines is the maximum number of lines joined into a multi-line query
+const maxQueryLines = 100
+
+// DefaultInternalSuffixes are commonly used private domain suffixes
+var DefaultInternalSuffixes = []string{"corp", "int", "internal", "lan", "local"}
+
+// Match is a replacement made by a sanitization rule
+type Match struct {
+	Line        int    `json:"line"`
+	Rule        string `json:"rule"`
+	Original    string `json:"original,omitempty"`
+	Replacement string `json:"replacement"`
+}
+
+// Sanitizer applies the sanitization rules to files. The same Sanitizer should be used
+// for all the files of a data collection so aliases are consistent across files.
+// A Sanitizer is not safe for concurrent use.
+type Sanitizer struct {
+	opts    Options
+	aliases *Aliases
+	secrets map[string]bool
+	// knownHostsRE matches any of opts.KnownHosts. It is nil if there are no known hosts.
+	knownHostsRE     *regexp.Regexp
+	internalSuffixes []string
+	keepPatterns     []string
+	// digests maps digest texts to performance_schema digests. See LearnDigests.
+	digests map[string]string
+
+	// State of the file being sanitized
+	line          int
+	inCreateTable bool
+	inPrivateKey  bool
+	// inJoinedQuery is true for the lines of a multi-line query
+	inJoinedQuery bool
+	userTable     userTable
+	report        bool
+	matches       []Match
+}
+
+// New returns a Sanitizer using opts. If aliases is nil, a new aliases map is created.
+func New(opts Options, aliases *Aliases) *Sanitizer {
+	if aliases == nil {
+		aliases = NewAliases()
+	}
+	secrets := make(map[string]bool)
+	for _, kind := range opts.Secrets {
+		secrets[kind] = true
+	}
+	suffixes := []string{}
+	for _, suffix := range opts.InternalSuffixes {
+		if suffix = strings.ToLower(strings.Trim(suffix, ". ")); suffix != "" {
+			suffixes = append(suffixes, suffix)
+		}
+	}
+	keep := []string{}
+	for _, pattern := range opts.Keep {
+		if pattern = strings.ToLower(strings.TrimSpace(pattern)); pattern != "" {
+			keep = append(keep, pattern)
+		}
+	}
+	return &Sanitizer{
+		opts:             opts,
+		aliases:          aliases,
+		secrets:          secrets,
+		knownHostsRE:     knownHostsRegexp(opts.KnownHosts),
+		internalSuffixes: suffixes,
+		keepPatterns:     keep,
+		digests:          make(map[string]string),
 	}
 }
 
-func Sanitize(lines []string, hostnames, queries bool) []string {
-	joined := joinQueryLines(lines)
-	if queries {
-		sanitizeQueries(joined)
+// Sanitize applies the sanitization rules to lines. It returns as many lines as lines has.
+func (s *Sanitizer) Sanitize(lines []string) []string {
+	s.line = 0
+	s.inCreateTable = false
+	s.inPrivateKey = false
+	s.userTable = userTable{}
+	if s.opts.Queries && s.opts.QueryMode == QueryDigest {
+		lines = append([]string{}, lines...)
+		s.replaceDigestTexts(lines)
+		s.line = 0
 	}
-	if hostnames {
-		sanitizeHostnames(joined)
+	sanitized := make([]string, 0, len(lines))
+	for _, group := range joinQueryLines(lines) {
+		s.inJoinedQuery = len(group) > 1
+		if s.inJoinedQuery && s.opts.Queries {
+			// Multi-line queries are fingerprinted or dropped as a whole. The result goes
+			// into the first line and the other lines are left empty so the line numbers
+			// don't change.
+			s.line++
+			query := strings.Split(s.sanitizeLine(strings.Join(group, "\n")), "\n")
+			s.line += len(group) - 1
+			sanitized = append(sanitized, fitLines(query, len(group))...)
+			continue
+		}
+		for _, line := range group {
+			s.line++
+			sanitized = append(sanitized, s.sanitizeLine(line))
+		}
 	}
-	return joined
+	return sanitized
 }
 
-func sanitizeHostnames(lines []string) {
-	for i := range lines {
-		lines[i] = hostnameRE.ReplaceAllStringFunc(lines[i], replaceHostname)
+// fitLines returns lines as exactly n lines, adding empty lines or joining the extra
+// lines into the last one
+func fitLines(lines []string, n int) []string {
+	if len(lines) > n {
+		lines = append(lines[:n-1], strings.Join(lines[n-1:], " "))
+	}
+	for len(lines) < n {
+		lines = append(lines, "")
 	}
+	return lines
 }
 
-func sanitizeQueries(lines []string) {
-	for i := range lines {
-		for _, re := range queryInLineRe {
-			lines[i] = re.ReplaceAllStringFunc(lines[i], queryToFingerprint)
+// Matches returns the list of replacements SanitizeFile would make in lines
+func (s *Sanitizer) Matches(fileType string, lines []string) []Match {
+	s.report = true
+	s.matches = nil
+	defer func() {
+		s.report = false
+		s.matches = nil
+	}()
+
+	s.SanitizeFile(fileType, lines)
+	return s.matches
+}
+
+func (s *Sanitizer) sanitizeLine(line string) string {
+	// Checked before sanitizeQueries updates the CREATE TABLE state
+	inSQL := s.inCreateTable || s.inJoinedQuery
+	if len(s.secrets) > 0 {
+		line = s.sanitizeSecrets(line)
+	}
+	if s.opts.UUIDs {
+		line = s.aliasUUIDs(line)
+	}
+	if s.opts.Users {
+		line = s.sanitizeUsers(line)
+	}
+	// Credentials and emails go first so their hosts are not taken as plain hostnames
+	if s.opts.Credentials {
+		line = s.sanitizeCredentials(line)
+	}
+	if s.opts.Emails {
+		line = s.sanitizeEmails(line)
+	}
+	if s.opts.Queries || s.opts.Identifiers {
+		line = s.sanitizeQueries(line)
+	}
+	if s.opts.IPs {
+		line = s.sanitizeIPs(line)
+	}
+	sqlStart := 0
+	if !inSQL {
+		sqlStart = queryStart(line)
+	}
+	if s.opts.Hostnames {
+		line = s.replaceKnownHosts(line)
+		line = s.sanitizeHostnames(line, sqlStart)
+		sqlStart = queryStart(line)
+	}
+	// After the host names, so the known hosts in file names get their host aliases
+	if s.opts.Paths && !inSQL {
+		line = s.sanitizePathsBefore(line, sqlStart)
+	}
+	return line
+}
+
+// SanitizeFileName replaces the known hosts found in a file name, like the host name in
+// binary log or pt-stalk file names.
+func (s *Sanitizer) SanitizeFileName(name string) string {
+	if !s.opts.Hostnames || s.knownHostsRE == nil {
+		return name
+	}
+	return s.knownHostsRE.ReplaceAllStringFunc(name, func(host string) string {
+		return s.alias(KindHost, host)
+	})
+}
+
+// replace returns replacement, or original if it must be kept, and if a report was
+// requested, records the match
+func (s *Sanitizer) replace(rule, original, replacement string) string {
+	if s.isKept(original) {
+		return original
+	}
+	if s.report && original != replacement {
+		s.matches = append(s.matches, Match{
+			Line:        s.line,
+			Rule:        rule,
+			Original:    original,
+			Replacement: replacement,
+		})
+	}
+	return replacement
+}
+
+// alias returns the alias of value or value itself if it must be kept
+func (s *Sanitizer) alias(kind, value string) string {
+	if s.isKept(value) {
+		return value
+	}
+	return s.aliases.Get(kind, value)
+}
+
+// isKept returns true if value matches any of the Keep patterns
+func (s *Sanitizer) isKept(value string) bool {
+	if len(s.keepPatterns) == 0 {
+		return false
+	}
+	value = strings.ToLower(value)
+	for _, pattern := range s.keepPatterns {
+		if value == pattern || strings.HasSuffix(value, "."+pattern) {
+			return true
+		}
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
 		}
 	}
+	return false
 }
 
-func joinQueryLines(lines []string) []string {
-	inQuery := false
-	joined := []string{}
-	queryString := ""
+// sanitizeQueries replaces queries by their fingerprints and/or obfuscates the identifiers
+// in them. When identifiers obfuscation is enabled, identifiers outside queries are also
+// obfuscated.
+func (s *Sanitizer) sanitizeQueries(line string) string {
+	if s.inJoinedQuery {
+		// The whole query or a line of a multi-line query
+		if s.opts.Queries {
+			return s.sanitizeQuery(line)
+		}
+		return s.obfuscateIdentifiers(line)
+	}
+	if s.inCreateTable {
+		// Column and index definitions of a multi-line CREATE TABLE
+		s.inCreateTable = !createTableClose.MatchString(line)
+		return s.obfuscateIdentifiers(line)
+	}
+	start := queryStart(line)
+	if start < 0 {
+		if s.opts.Identifiers {
+			return s.obfuscateStructuredIdentifiers(line)
+		}
+		return line
+	}
+	end := queryEnd(line, start)
+	line = line[:start] + s.sanitizeQuery(line[start:end]) + line[end:]
+	s.inCreateTable = s.opts.Identifiers && createTableOpen.MatchString(line)
+	return line
+}
 
-	separator := ""
+func (s *Sanitizer) sanitizeQuery(q string) string {
+	if s.opts.Queries && s.opts.QueryMode == QueryDrop {
+		return s.replace(RuleQueries, q, DroppedQuery)
+	}
+	if s.opts.Queries && s.opts.QueryMode == QueryDigest {
+		return s.replace(RuleQueries, q, s.queryDigest(q))
+	}
+	if s.opts.Queries && s.opts.QueryMode == QueryShape {
+		// Identifiers go first because the shapes, like ?int x 500, contain words
+		shaped := q
+		if s.opts.Identifiers {
+			shaped = s.obfuscateIdentifiers(q)
+		}
+		return s.replace(RuleQueries, q, s.withKeptComments(q, queryShape(shaped)))
+	}
+	original := q
+	if s.opts.Queries {
+		q = s.replace(RuleQueries, q, queryToFingerprint(q))
+	}
+	if s.opts.Identifiers {
+		q = s.obfuscateIdentifiers(q)
+	}
+	if s.opts.Queries && q != original {
+		q = s.withKeptComments(original, q)
+	}
+	return q
+}
+
+// queryEnd returns where the query starting at start ends. In tab separated output, like
+// the one of mysql --batch, the query ends at the next tab.
+func queryEnd(line string, start int) int {
+	if strings.Contains(line[:start], "\t") {
+		if end := strings.Index(line[start:], "\t"); end >= 0 {
+			return start + end
+		}
+	}
+	return len(line)
+}
+
+// queryStart returns the position where the first query in the line starts or -1 if
+// there are no queries in the line
+func queryStart(line string) int {
+	start := -1
+	for _, re := range queryInLineRe {
+		if loc := re.FindStringSubmatchIndex(line); loc != nil && (start < 0 || loc[2] < start) {
+			start = loc[2]
+		}
+	}
+	return start
+}
+
+// joinQueryLines groups the lines of each multi-line query. Every other line is a group by
+// itself. A query ends at its semicolon, before a blank line, a log header like # Time: or
+// a line that doesn't look like SQL, or after maxQueryLines lines, so a text that only
+// starts like a statement doesn't swallow the rest of the file.
+func joinQueryLines(lines []string) [][]string {
+	groups := [][]string{}
+	var query []string
 
 	for _, line := range lines {
-		if !inQuery && mightBeAQueryLine(line) {
-			inQuery = true
-		}
-		if inQuery {
-			if strings.HasPrefix(line, "***") {
-				joined = append(joined, queryString)
-				queryString = ""
-				joined = append(joined, line)
-				inQuery = false
-				separator = ""
-				continue
-			}
-			queryString += separator + line
-			separator = "\n"
-			if !strings.HasSuffix(strings.TrimSpace(line), ";") {
-				continue
-			}
-			inQuery = false
-			separator = ""
-			joined = append(joined, queryString)
-			queryString = ""
+		if query != nil && !continuesQuery(query[len(query)-1], line) {
+			groups = append(groups, query)
+			query = nil
+		}
+		if query == nil && !mightBeAQueryLine(line) {
+			groups = append(groups, []string{line})
+			continue
+		}
+		query = append(query, line)
+		if strings.HasSuffix(strings.TrimSpace(line), ";") || len(query) >= maxQueryLines {
+			groups = append(groups, query)
+			query = nil
+		}
+	}
+	if query != nil {
+		groups = append(groups, query)
+	}
+	return groups
+}
+
+// continuesQuery returns true if line can be the next line of a multi-line query whose
+// last line is prev
+func continuesQuery(prev, line string) bool {
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "***") ||
+		fieldLineRe.MatchString(line) {
+		return false
+	}
+	return sqlOpenEndRe.MatchString(prev) || sqlContinuationRe.MatchString(line)
+}
+
+func (s *Sanitizer) replaceKnownHosts(line string) string {
+	if s.knownHostsRE == nil {
+		return line
+	}
+	return s.knownHostsRE.ReplaceAllStringFunc(line, func(host string) string {
+		return s.replace(RuleHostnames, host, s.alias(KindHost, host))
+	})
+}
+
+// knownHostsRegexp returns a regexp matching any of the hosts as a whole word, so
+// db-prod-07 is found in db-prod-07-bin.000123 but not in db-prod-070.
+func knownHostsRegexp(hosts []string) *regexp.Regexp {
+	seen := make(map[string]bool)
+	quoted := []string{}
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" || host == "localhost" || ignoredIPs[host] || seen[host] {
 			continue
 		}
-		joined = append(joined, line)
+		seen[host] = true
+		quoted = append(quoted, regexp.QuoteMeta(host))
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	// Longest names first so a FQDN is replaced as a whole instead of by its short name
+	sort.Slice(quoted, func(i, j int) bool {
+		if len(quoted[i]) != len(quoted[j]) {
+			return len(quoted[i]) > len(quoted[j])
+		}
+		return quoted[i] < quoted[j]
+	})
+	return regexp.MustCompile(`(?i)\b(` + strings.Join(quoted, "|") + `)\b`)
+}
+
+// sanitizeHostnames replaces the host names in line. SQL starts at sqlStart, or -1 if the
+// line has no SQL. Qualified names like shop.orders or category.name look like host names,
+// so in SQL only the strings and comments are checked and the identifiers are kept.
+func (s *Sanitizer) sanitizeHostnames(line string, sqlStart int) string {
+	if sqlStart < 0 {
+		return hostnameRE.ReplaceAllStringFunc(line, s.replaceHostname)
+	}
+	sqlEnd := queryEnd(line, sqlStart)
+	tokens := tokenize(line[sqlStart:sqlEnd])
+	for i, t := range tokens {
+		if t.kind == tokenString || t.kind == tokenComment {
+			tokens[i].text = hostnameRE.ReplaceAllStringFunc(t.text, s.replaceSQLHostname)
+		}
 	}
-	return joined
+	return hostnameRE.ReplaceAllStringFunc(line[:sqlStart], s.replaceHostname) + joinTokens(tokens) +
+		hostnameRE.ReplaceAllStringFunc(line[sqlEnd:], s.replaceHostname)
 }
 
-func replaceHostname(s string) string {
-	if strings.HasSuffix(s, ":") {
-		return "<hostname>:"
+// replaceHostname replaces a hostname by its alias
+func (s *Sanitizer) replaceHostname(name string) string {
+	if !s.isHostname(name) {
+		return name
+	}
+	return s.replace(RuleHostnames, name, s.alias(KindHost, name))
+}
+
+// replaceSQLHostname replaces a hostname found in the strings and comments of a query. A
+// name with two labels, like 'sakila.actor', is more likely a qualified table or column
+// name than a host, so it is only replaced if it ends in a country code or a legacy TLD.
+func (s *Sanitizer) replaceSQLHostname(name string) string {
+	if strings.Count(name, ".") == 1 && !s.hasInternalSuffix(name) {
+		tld := strings.ToLower(name[strings.Index(name, ".")+1:])
+		if len(tld) != 2 && !legacyTLDs[tld] {
+			return name
+		}
+	}
+	return s.replaceHostname(name)
+}
+
+// hasInternalSuffix returns true if name ends in one of the internal suffixes
+func (s *Sanitizer) hasInternalSuffix(name string) bool {
+	name = strings.ToLower(name)
+	for _, suffix := range s.internalSuffixes {
+		if strings.HasSuffix(name, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHostname returns true if name ends in a public suffix, like com or co.uk, or in one
+// of the internal suffixes. Names with two labels ending in a common file extension that
+// is also a country code, like README.md, are not host names.
+func (s *Sanitizer) isHostname(name string) bool {
+	if s.hasInternalSuffix(name) {
+		return true
+	}
+	name = strings.ToLower(name)
+	suffix, icann := publicsuffix.PublicSuffix(name)
+	// Private suffixes, like blogspot.com, are also domains. Unknown suffixes are returned
+	// as the last label and not ICANN managed.
+	if (!icann && !strings.Contains(suffix, ".")) || suffix == name {
+		return false
 	}
-	return "hostname"
+	return strings.Count(name, ".") > 1 || !fileExtensions[suffix]
 }
 
 func queryToFingerprint(q string) string {
@@ -0,0 +1,151 @@
+package sanitize
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// Scan rule names
+const (
+	ScanSQLLiteral  = "sql-literal"
+	ScanIP          = "ip"
+	ScanEmail       = "email"
+	ScanDSNPassword = "dsn-password"
+	ScanPrivateKey  = "private-key"
+	ScanHighEntropy = "high-entropy"
+	ScanSecret      = "secret"
+)
+
+const (
+	minEntropyTokenLength = 20
+	minTokenEntropy       = 4.5
+)
+
+var (
+	ipRE           = regexp.MustCompile(`\b((25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`)
+	privateKeyRE   = regexp.MustCompile(`-----BEGIN ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)
+	entropyTokenRE = regexp.MustCompile(`[A-Za-z0-9+/=_\-]{20,}`)
+
+	// Addresses that don't identify a host
+	ignoredIPs = map[string]bool{
+		"0.0.0.0":         true,
+		"127.0.0.1":       true,
+		"255.255.255.255": true,
+	}
+)
+
+// Finding is a possible leak found by Scan
+type Finding struct {
+	Line  int
+	Rule  string
+	Value string
+}
+
+// Scan searches lines for data that should have been sanitized: SQL literals that were not
+// fingerprinted, IP and email addresses, passwords in DSNs, private keys, API keys and
+// tokens, high entropy strings and the given secrets. The statements written by mysqlbinlog
+// to set the context of the events, like SET TIMESTAMP=1520256301, are kept by the
+// sanitizer, so their values are not reported.
+func Scan(lines []string, secrets []string) []Finding {
+	findings := []Finding{}
+	add := func(line int, rule, value string) {
+		findings = append(findings, Finding{Line: line, Rule: rule, Value: value})
+	}
+
+	for i, line := range lines {
+		n := i + 1
+		for _, secret := range secrets {
+			if secret != "" && strings.Contains(line, secret) {
+				add(n, ScanSecret, secret)
+			}
+		}
+		if m := privateKeyRE.FindString(line); m != "" {
+			add(n, ScanPrivateKey, m)
+		}
+		for _, p := range secretPatterns {
+			for _, m := range p.re.FindAllString(line, -1) {
+				add(n, p.kind, m)
+			}
+		}
+		for _, m := range dsnPasswords(line) {
+			add(n, ScanDSNPassword, m)
+		}
+		for _, m := range emailRE.FindAllString(line, -1) {
+			add(n, ScanEmail, m)
+		}
+		for _, m := range ipRE.FindAllString(line, -1) {
+			if !ignoredIPs[m] {
+				add(n, ScanIP, m)
+			}
+		}
+		if start := queryStart(line); start >= 0 && !binlogSessionRE.MatchString(line[start:]) {
+			if literal := firstLiteral(line[start:]); literal != "" {
+				add(n, ScanSQLLiteral, literal)
+			}
+		}
+		for _, m := range entropyTokenRE.FindAllString(line, -1) {
+			if isHighEntropy(m) {
+				add(n, ScanHighEntropy, m)
+			}
+		}
+	}
+	return findings
+}
+
+// firstLiteral returns the first string or number literal in a query. The sizes and
+// counts of the QueryShape placeholders, like ?str(3), IN (?int x 3) or the x 2 of the
+// collapsed rows, are not literals.
+func firstLiteral(q string) string {
+	tokens := []token{}
+	for _, tok := range tokenize(q) {
+		if tok.kind != tokenSpace && tok.kind != tokenComment {
+			tokens = append(tokens, tok)
+		}
+	}
+	for i, tok := range tokens {
+		if tok.kind == tokenNumber && i >= 2 && isShapeSize(tokens[i-2], tokens[i-1]) {
+			continue
+		}
+		if tok.kind == tokenString || tok.kind == tokenNumber {
+			return tok.text
+		}
+	}
+	return ""
+}
+
+// isShapeSize returns true if a number preceded by the tokens before and op is the size of
+// a placeholder, like in ?str(3), or the count of a collapsed list or row, like in ?int x 3
+// or (?int, ?str(3)) x 2
+func isShapeSize(before, op token) bool {
+	if op.text == "(" {
+		return before.kind == tokenPlaceholder
+	}
+	return strings.ToLower(op.text) == "x" && (before.kind == tokenPlaceholder || before.text == ")")
+}
+
+// isHighEntropy returns true if s looks like a random token: long enough, mixing letters
+// and digits and with a Shannon entropy above minTokenEntropy bits per character.
+func isHighEntropy(s string) bool {
+	if len(s) < minEntropyTokenLength {
+		return false
+	}
+	if !strings.ContainsAny(s, "0123456789") || strings.ToLower(s) == s || strings.ToUpper(s) == s {
+		return false
+	}
+	return shannonEntropy(s) > minTokenEntropy
+}
+
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	entropy := 0.0
+	length := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
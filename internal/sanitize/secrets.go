@@ -0,0 +1,184 @@
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Secret types. They are also used as rule names in the matches report and, between angle
+// brackets, as the text that replaces the secret.
+const (
+	SecretPrivateKey  = "private-key"
+	SecretAWSKey      = "aws-access-key"
+	SecretGitHubToken = "github-token"
+	SecretJWT         = "jwt"
+	SecretPassword    = "password"
+	SecretHighEntropy = "high-entropy"
+)
+
+// SecretTypes lists the secret types in the order they are detected
+var SecretTypes = []string{
+	SecretPrivateKey,
+	SecretAWSKey,
+	SecretGitHubToken,
+	SecretJWT,
+	SecretPassword,
+	SecretHighEntropy,
+}
+
+// Words in the names of the keys that hold secrets
+const secretKeyWords = `password|passwd|secret|token|api[_\-]?key`
+
+// settingKeys are the MySQL variables and options whose names look like the ones of keys
+// holding secrets, but whose values are settings, like innodb_ft_max_token_size = 84 or
+// validate_password.policy = MEDIUM. They are compared in lower case with underscores.
+var settingKeys = []string{
+	"caching_sha2_password_",
+	"default_password_lifetime",
+	"disconnect_on_expired_password",
+	"generated_random_password_length",
+	"innodb_ft_max_token_size",
+	"innodb_ft_min_token_size",
+	"mysql_native_password_proxy_users",
+	"ngram_token_size",
+	"old_passwords",
+	"password_history",
+	"password_require_current",
+	"password_reuse_interval",
+	"sha256_password_",
+	"validate_password",
+}
+
+var (
+	// Secrets with a known format
+	secretPatterns = []struct {
+		kind string
+		re   *regexp.Regexp
+	}{
+		{SecretAWSKey, regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`)},
+		{SecretGitHubToken, regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+		{SecretJWT, regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]+\.eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+`)},
+	}
+	// password = value, api_key: value, AWS_SECRET_ACCESS_KEY=value, "password": "value",
+	// 'password' => 'value', etc.
+	passwordAssignmentRE = regexp.MustCompile(`(?i)(["']?[\w.\-]*(?:` + secretKeyWords + `)[\w.\-]*["']?)` +
+		`(\s*(?:=>|[:=])\s*)("[^"]*"|'[^']*'|[^\s,;&"']+)`)
+	// <password>value</password> in XML files. The closing tag is checked by the code.
+	passwordElementRE = regexp.MustCompile(`(?i)(<([\w.\-:]*(?:` + secretKeyWords + `)[\w.\-:]*)(?:\s[^>]*)?>)` +
+		`([^<]+)(</([\w.\-:]+)\s*>)`)
+	// CREATE USER ... IDENTIFIED BY 'value', also with an authentication plugin
+	identifiedByRE = regexp.MustCompile(`(?i)(\bIDENTIFIED\s+(?:WITH\s+\S+\s+)?(?:BY|AS)\s+(?:PASSWORD\s+)?)` +
+		`('(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*")`)
+	// The YES or NO of (using password: YES) in the MySQL access denied errors
+	usingPasswordRE = regexp.MustCompile(`^(YES|NO)\)$`)
+	// Values that are settings instead of secrets, like password_require_current = OFF
+	notSecretValueRE = regexp.MustCompile(`(?i)^([+\-]?\d+(\.\d+)?|on|off|true|false|yes|no|null)$`)
+	privateKeyEndRE  = regexp.MustCompile(`-----END ([A-Z0-9]+ )*PRIVATE KEY( BLOCK)?-----`)
+)
+
+func redactedSecret(kind string) string {
+	return "<" + kind + ">"
+}
+
+// sanitizeSecrets replaces the enabled secret types by a token naming the secret type
+func (s *Sanitizer) sanitizeSecrets(line string) string {
+	if s.secrets[SecretPrivateKey] {
+		if line = s.sanitizePrivateKeys(line); s.inPrivateKey {
+			return line
+		}
+	}
+	for _, p := range secretPatterns {
+		if !s.secrets[p.kind] {
+			continue
+		}
+		kind := p.kind
+		line = p.re.ReplaceAllStringFunc(line, func(secret string) string {
+			return s.replace(kind, secret, redactedSecret(kind))
+		})
+	}
+	if s.secrets[SecretPassword] {
+		line = s.sanitizePasswords(line)
+	}
+	if s.secrets[SecretHighEntropy] {
+		line = entropyTokenRE.ReplaceAllStringFunc(line, func(token string) string {
+			if !isHighEntropy(token) {
+				return token
+			}
+			return s.replace(SecretHighEntropy, token, redactedSecret(SecretHighEntropy))
+		})
+	}
+	return line
+}
+
+// sanitizePasswords replaces the values of the keys holding passwords and other secrets in
+// assignments, JSON and XML, and the passwords of the IDENTIFIED BY clauses
+func (s *Sanitizer) sanitizePasswords(line string) string {
+	line = identifiedByRE.ReplaceAllStringFunc(line, func(match string) string {
+		m := identifiedByRE.FindStringSubmatch(match)
+		return m[1] + s.replace(SecretPassword, m[2], redactedSecret(SecretPassword))
+	})
+	line = passwordAssignmentRE.ReplaceAllStringFunc(line, func(match string) string {
+		m := passwordAssignmentRE.FindStringSubmatch(match)
+		key, sep, value := m[1], m[2], m[3]
+		if isNotSecret(key, strings.Trim(value, `"'`)) || usingPasswordRE.MatchString(value) {
+			return match
+		}
+		return key + sep + s.replace(SecretPassword, value, redactedSecret(SecretPassword))
+	})
+	return passwordElementRE.ReplaceAllStringFunc(line, func(match string) string {
+		m := passwordElementRE.FindStringSubmatch(match)
+		openTag, key, value, closeTag := m[1], m[2], m[3], m[4]
+		if !strings.EqualFold(key, m[5]) || isNotSecret(key, strings.TrimSpace(value)) {
+			return match
+		}
+		return openTag + s.replace(SecretPassword, value, redactedSecret(SecretPassword)) + closeTag
+	})
+}
+
+// isNotSecret returns true if the value of key is not a secret: it was already redacted,
+// it is a number or a boolean, or key is a setting like innodb_ft_max_token_size.
+func isNotSecret(key, value string) bool {
+	if isRedacted(value) || notSecretValueRE.MatchString(value) {
+		return true
+	}
+	key = strings.ToLower(strings.Replace(strings.Trim(key, `"'`), "-", "_", -1))
+	for _, setting := range settingKeys {
+		if strings.Contains(key, setting) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizePrivateKeys replaces PEM private key blocks. The BEGIN line is replaced by
+// <private-key> and the rest of the block by empty lines so line numbers don't change.
+func (s *Sanitizer) sanitizePrivateKeys(line string) string {
+	// Only the BEGIN line is reported, as one match per key
+	if s.inPrivateKey {
+		end := privateKeyEndRE.FindStringIndex(line)
+		if end == nil {
+			return ""
+		}
+		s.inPrivateKey = false
+		return line[end[1]:]
+	}
+
+	begin := privateKeyRE.FindStringIndex(line)
+	if begin == nil {
+		return line
+	}
+	end := privateKeyEndRE.FindStringIndex(line[begin[1]:])
+	if end == nil {
+		s.inPrivateKey = true
+		return line[:begin[0]] + s.replace(SecretPrivateKey, line[begin[0]:], redactedSecret(SecretPrivateKey))
+	}
+	stop := begin[1] + end[1]
+	return line[:begin[0]] + s.replace(SecretPrivateKey, line[begin[0]:stop], redactedSecret(SecretPrivateKey)) +
+		line[stop:]
+}
+
+// isRedacted returns true for values that were already replaced or that are not actual
+// values, like the placeholders in query fingerprints.
+func isRedacted(value string) bool {
+	return value == "" || value == "?" || (strings.HasPrefix(value, "<") && strings.HasSuffix(value, ">"))
+}
@@ -0,0 +1,178 @@
+package sanitize
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	dateRE     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	datetimeRE = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(\.\d{1,6})?$`)
+	timeRE     = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d{1,6})?$`)
+)
+
+// shapeToken is a significant token of a query in QueryShape mode
+type shapeToken struct {
+	text string
+	// space is true if the token was preceded by white space or a comment
+	space   bool
+	literal bool
+}
+
+// queryShape replaces the literals in q by placeholders that keep their type and size,
+// like ?int, ?str(32) or ?date, so implicit conversions and range sizes can still be
+// reasoned about. Lists of values with the same shape are collapsed, like IN (?int x 500),
+// and so are the identical rows of multi-row inserts.
+func queryShape(q string) string {
+	tokens := []shapeToken{}
+	space := false
+	for _, tok := range tokenize(strings.TrimSpace(q)) {
+		switch tok.kind {
+		case tokenSpace, tokenComment:
+			space = len(tokens) > 0
+			continue
+		case tokenString, tokenNumber:
+			tokens = append(tokens, shapeToken{literalShape(tok), space, true})
+		default:
+			tokens = append(tokens, shapeToken{tok.text, space, false})
+		}
+		space = false
+	}
+	tokens = collapseRows(collapseLists(tokens))
+
+	var sb strings.Builder
+	for _, tok := range tokens {
+		if tok.space {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(tok.text)
+	}
+	return sb.String()
+}
+
+// literalShape returns the placeholder of a string or number literal
+func literalShape(tok token) string {
+	if tok.kind == tokenNumber {
+		switch {
+		case strings.HasPrefix(strings.ToLower(tok.text), "0x"):
+			return "?hex"
+		case strings.ContainsAny(tok.text, "eE"):
+			return "?float"
+		case strings.Contains(tok.text, "."):
+			return "?decimal"
+		}
+		return "?int"
+	}
+	value := unquote(tok.text)
+	switch {
+	case dateRE.MatchString(value):
+		return "?date"
+	case datetimeRE.MatchString(value):
+		return "?datetime"
+	case timeRE.MatchString(value):
+		return "?time"
+	}
+	return fmt.Sprintf("?str(%d)", utf8.RuneCountInString(value))
+}
+
+// collapseLists replaces lists of literals with the same shape, like (?int, ?int, ?int),
+// by (?int x 3)
+func collapseLists(tokens []shapeToken) []shapeToken {
+	collapsed := []shapeToken{}
+	for i := 0; i < len(tokens); i++ {
+		collapsed = append(collapsed, tokens[i])
+		if tokens[i].text != "(" {
+			continue
+		}
+		count, end := 0, -1
+		for j := i + 1; j+1 < len(tokens); j += 2 {
+			if !tokens[j].literal || tokens[j].text != tokens[i+1].text {
+				break
+			}
+			count++
+			if tokens[j+1].text == ")" {
+				end = j + 1
+				break
+			}
+			if tokens[j+1].text != "," {
+				break
+			}
+		}
+		if end < 0 || count < 2 {
+			continue
+		}
+		list := tokens[i+1]
+		list.text = fmt.Sprintf("%s x %d", list.text, count)
+		collapsed = append(collapsed, list, tokens[end])
+		i = end
+	}
+	return collapsed
+}
+
+// collapseRows replaces the identical rows after VALUES, like (?int, ?str(3)), (?int, ?str(3)),
+// by (?int, ?str(3)) x 2
+func collapseRows(tokens []shapeToken) []shapeToken {
+	collapsed := []shapeToken{}
+	for i := 0; i < len(tokens); i++ {
+		collapsed = append(collapsed, tokens[i])
+		kw := strings.ToUpper(tokens[i].text)
+		if (kw != "VALUES" && kw != "VALUE") || i+1 >= len(tokens) {
+			continue
+		}
+		row := rowEnd(tokens, i+1)
+		if row < 0 {
+			continue
+		}
+		first := tokens[i+1 : row+1]
+		count, last := 1, row
+		for last+2 < len(tokens) && tokens[last+1].text == "," {
+			next := rowEnd(tokens, last+2)
+			if next < 0 || !sameRow(first, tokens[last+2:next+1]) {
+				break
+			}
+			count++
+			last = next
+		}
+		if count < 2 {
+			continue
+		}
+		collapsed = append(collapsed, first...)
+		collapsed[len(collapsed)-1].text += fmt.Sprintf(" x %d", count)
+		i = last
+	}
+	return collapsed
+}
+
+// rowEnd returns the position of the parenthesis closing the one at start or -1
+func rowEnd(tokens []shapeToken, start int) int {
+	if tokens[start].text != "(" {
+		return -1
+	}
+	depth := 0
+	for i := start; i < len(tokens); i++ {
+		switch tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func sameRow(a, b []shapeToken) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].text != b[i].text {
+			return false
+		}
+	}
+	return true
+}
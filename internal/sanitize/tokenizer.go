@@ -0,0 +1,189 @@
+package sanitize
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenWord tokenKind = iota
+	tokenQuotedIdent
+	tokenString
+	tokenNumber
+	tokenPlaceholder
+	tokenVariable
+	tokenComment
+	tokenPunct
+	tokenSpace
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits a query into tokens. It is not a full SQL lexer but it knows enough
+// about quoting and comments to tell identifiers apart from literals and keywords.
+// Joining the text of all the returned tokens gives back the original query.
+func tokenize(q string) []token {
+	tokens := []token{}
+	for i := 0; i < len(q); {
+		c := q[i]
+		start := i
+		switch {
+		case isSpace(c):
+			for i < len(q) && isSpace(q[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenSpace, q[start:i]})
+		case c == '`':
+			i = scanQuoted(q, i, '`')
+			tokens = append(tokens, token{tokenQuotedIdent, q[start:i]})
+		case c == '\'' || c == '"':
+			i = scanQuoted(q, i, c)
+			tokens = append(tokens, token{tokenString, q[start:i]})
+		case c == '/' && i+1 < len(q) && q[i+1] == '*':
+			end := strings.Index(q[i+2:], "*/")
+			if end < 0 {
+				i = len(q)
+			} else {
+				i += end + 4
+			}
+			tokens = append(tokens, token{tokenComment, q[start:i]})
+		case c == '#' || (c == '-' && strings.HasPrefix(q[i:], "-- ")):
+			for i < len(q) && q[i] != '\n' {
+				i++
+			}
+			tokens = append(tokens, token{tokenComment, q[start:i]})
+		case c == '?':
+			i++
+			for i < len(q) && (q[i] == '+' || isWordChar(q[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenPlaceholder, q[start:i]})
+		case c == '@':
+			for i < len(q) && q[i] == '@' {
+				i++
+			}
+			if i < len(q) && (q[i] == '`' || q[i] == '\'' || q[i] == '"') {
+				i = scanQuoted(q, i, q[i])
+			}
+			for i < len(q) && (isWordChar(q[i]) || q[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenVariable, q[start:i]})
+		case isDigit(c) || (c == '.' && i+1 < len(q) && isDigit(q[i+1]) && !prevIsWord(tokens)):
+			i = scanNumber(q, i)
+			// Identifiers may start with digits, like 1table
+			if i < len(q) && isWordChar(q[i]) && !strings.ContainsAny(q[start:i], ".") {
+				for i < len(q) && isWordChar(q[i]) {
+					i++
+				}
+				tokens = append(tokens, token{tokenWord, q[start:i]})
+				continue
+			}
+			tokens = append(tokens, token{tokenNumber, q[start:i]})
+		case isWordChar(c):
+			for i < len(q) && isWordChar(q[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokenWord, q[start:i]})
+		default:
+			i++
+			tokens = append(tokens, token{tokenPunct, q[start:i]})
+		}
+	}
+	return tokens
+}
+
+func joinTokens(tokens []token) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteString(t.text)
+	}
+	return sb.String()
+}
+
+// scanQuoted returns the position right after the closing quote of the quoted string
+// starting at pos. Doubled quotes and backslash escapes are handled.
+func scanQuoted(q string, pos int, quote byte) int {
+	i := pos + 1
+	for i < len(q) {
+		switch q[i] {
+		case '\\':
+			if quote != '`' {
+				i += 2
+				continue
+			}
+		case quote:
+			if i+1 < len(q) && q[i+1] == quote {
+				i += 2
+				continue
+			}
+			return i + 1
+		}
+		i++
+	}
+	return len(q)
+}
+
+func scanNumber(q string, pos int) int {
+	i := pos
+	if strings.HasPrefix(strings.ToLower(q[i:]), "0x") {
+		i += 2
+		for i < len(q) && isHexDigit(q[i]) {
+			i++
+		}
+		return i
+	}
+	for i < len(q) && (isDigit(q[i]) || q[i] == '.') {
+		i++
+	}
+	if i < len(q) && (q[i] == 'e' || q[i] == 'E') {
+		j := i + 1
+		if j < len(q) && (q[j] == '+' || q[j] == '-') {
+			j++
+		}
+		if j < len(q) && isDigit(q[j]) {
+			i = j
+			for i < len(q) && isDigit(q[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
+
+func prevIsWord(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	k := tokens[len(tokens)-1].kind
+	return k == tokenWord || k == tokenQuotedIdent
+}
+
+// unquote removes the enclosing backticks or quotes from a token text
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '`' || s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0] {
+		q := string(s[0])
+		return strings.Replace(s[1:len(s)-1], q+q, q, -1)
+	}
+	return s
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
@@ -0,0 +1,144 @@
+package sanitize
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+var (
+	// Accounts created by MySQL and the users shown for its internal threads. They are
+	// never aliased.
+	systemUsers = map[string]bool{
+		"event_scheduler":      true,
+		"mysql.infoschema":     true,
+		"mysql.session":        true,
+		"mysql.sys":            true,
+		"root":                 true,
+		"system user":          true,
+		"unauthenticated user": true,
+	}
+
+	// User: karl, in the vertical output of the processlist and mysql.user
+	verticalUserRE = regexp.MustCompile(`^(\s*User: )(.*?)(\s*)$`)
+	// # User@Host: karl[karl] @ localhost [], in the slow log
+	slowLogUserRE = regexp.MustCompile(`^(# User@Host: )([^\[\s]*)\[([^\]]*)\]`)
+	// MySQL thread id 5, OS thread handle 0x7f, query id 12 localhost karl updating, in
+	// the transactions of the InnoDB status. The host is followed by the IP address, if
+	// it is not the same, the user and the state.
+	innodbThreadRE = regexp.MustCompile(`^(MySQL thread id \d+, OS thread handle \w+, query id \d+ )(.*)$`)
+	innodbHostRE   = regexp.MustCompile(`^(localhost|[a-z0-9][A-Za-z0-9.\-_]*|[0-9a-fA-F:.]+)$`)
+	// 'karl'@'%' in SHOW GRANTS and CREATE USER, also with backticks or double quotes
+	accountRE = regexp.MustCompile("(['\"`])([^'\"`@]*)(['\"`]@['\"`])")
+	// Grants for karl@%, the header of SHOW GRANTS
+	grantsForRE = regexp.MustCompile(`^(Grants for )([^@\s]*)(@)`)
+)
+
+// userTable is the User column of the table being sanitized, like the ones in the
+// processlist and mysql.user outputs in the tab separated and table formats
+type userTable struct {
+	separator string
+	// Number of fields of the rows, 0 if no table has a User column
+	fields int
+	column int
+}
+
+// sanitizeUsers aliases the MySQL user names found in line. System users are kept.
+func (s *Sanitizer) sanitizeUsers(line string) string {
+	if m := verticalUserRE.FindStringSubmatch(line); m != nil {
+		return m[1] + s.aliasAccountUser(m[2]) + m[3]
+	}
+	if m := slowLogUserRE.FindStringSubmatchIndex(line); m != nil {
+		return line[:m[3]] + s.aliasAccountUser(line[m[4]:m[5]]) + "[" + s.aliasAccountUser(line[m[6]:m[7]]) + line[m[7]:]
+	}
+	if m := innodbThreadRE.FindStringSubmatch(line); m != nil {
+		return m[1] + s.sanitizeInnoDBThreadUser(m[2])
+	}
+	if m := grantsForRE.FindStringSubmatch(line); m != nil {
+		line = m[1] + s.aliasAccountUser(m[2]) + line[len(m[0])-1:]
+	}
+	line = accountRE.ReplaceAllStringFunc(line, func(match string) string {
+		m := accountRE.FindStringSubmatch(match)
+		return m[1] + s.aliasAccountUser(m[2]) + m[3]
+	})
+	return s.sanitizeUserColumn(line)
+}
+
+// sanitizeInnoDBThreadUser aliases the user in the host, IP address, user and state that
+// follow the query id in the InnoDB status transactions
+func (s *Sanitizer) sanitizeInnoDBThreadUser(text string) string {
+	if strings.HasPrefix(text, "system user") {
+		return text
+	}
+	words := strings.SplitN(text, " ", 4)
+	if !innodbHostRE.MatchString(words[0]) {
+		return text
+	}
+	user := 1
+	if len(words) > 2 && net.ParseIP(words[1]) != nil && words[1] != words[0] {
+		user = 2
+	}
+	if user >= len(words) || words[user] == "" {
+		return text
+	}
+	words[user] = s.aliasAccountUser(words[user])
+	return strings.Join(words, " ")
+}
+
+// sanitizeUserColumn aliases the User column of tables. The column is found in the
+// header of the table and used for the next rows with the same number of fields.
+func (s *Sanitizer) sanitizeUserColumn(line string) string {
+	for _, separator := range []string{"\t", "|"} {
+		fields := strings.Split(line, separator)
+		if len(fields) < 2 {
+			continue
+		}
+		for i, field := range fields {
+			if strings.TrimSpace(field) == "User" {
+				s.userTable = userTable{separator: separator, fields: len(fields), column: i}
+				return line
+			}
+		}
+	}
+	table := s.userTable
+	if table.fields == 0 || strings.HasPrefix(line, "+-") {
+		return line
+	}
+	fields := strings.Split(line, table.separator)
+	if len(fields) != table.fields {
+		s.userTable = userTable{}
+		return line
+	}
+	field := fields[table.column]
+	user := strings.TrimSpace(field)
+	if user == "" {
+		return line
+	}
+	start := strings.Index(field, user)
+	sanitized := field[:start] + s.aliasAccountUser(user)
+	if table.separator == "|" {
+		// Keep the table borders aligned if possible
+		spaces := len(field) - len(sanitized)
+		if spaces < 1 {
+			spaces = 1
+		}
+		sanitized += strings.Repeat(" ", spaces)
+	} else {
+		sanitized += field[start+len(user):]
+	}
+	fields[table.column] = sanitized
+	return strings.Join(fields, table.separator)
+}
+
+// aliasAccountUser aliases the user name of a MySQL account if the users rule is enabled.
+// System users are kept.
+func (s *Sanitizer) aliasAccountUser(user string) string {
+	if !s.opts.Users || user == "" || isSystemUser(user) || s.aliases.IsAlias(user) {
+		return user
+	}
+	return s.replace(RuleUsers, user, s.alias(KindUser, user))
+}
+
+func isSystemUser(user string) bool {
+	return systemUsers[strings.ToLower(user)]
+}
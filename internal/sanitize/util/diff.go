@@ -0,0 +1,79 @@
+package util
+
+import (
+	"fmt"
+)
+
+// UnifiedDiff returns a unified diff between the original and the sanitized lines.
+// Sanitization doesn't add or remove lines so lines are compared by position.
+func UnifiedDiff(name string, original, sanitized []string, context int) []string {
+	n := len(original)
+	if len(sanitized) > n {
+		n = len(sanitized)
+	}
+	changed := func(i int) bool {
+		if i >= len(original) || i >= len(sanitized) {
+			return true
+		}
+		return original[i] != sanitized[i]
+	}
+
+	diff := []string{}
+	for i := 0; i < n; i++ {
+		if !changed(i) {
+			continue
+		}
+		// Extend the hunk while the next change is within the context lines
+		start := max(i-context, 0)
+		end := i
+		for j := i; j < n && j <= end+2*context; j++ {
+			if changed(j) {
+				end = j
+			}
+		}
+		end = min(end+context, n-1)
+
+		if len(diff) == 0 {
+			diff = append(diff, "--- "+name+" (original)", "+++ "+name+" (sanitized)")
+		}
+		oldLines, newLines := []string{}, []string{}
+		hunk := []string{}
+		for j := start; j <= end; j++ {
+			if !changed(j) {
+				hunk = append(hunk, oldLines...)
+				hunk = append(hunk, newLines...)
+				oldLines, newLines = nil, nil
+				hunk = append(hunk, " "+original[j])
+				continue
+			}
+			if j < len(original) {
+				oldLines = append(oldLines, "-"+original[j])
+			}
+			if j < len(sanitized) {
+				newLines = append(newLines, "+"+sanitized[j])
+			}
+		}
+		hunk = append(hunk, oldLines...)
+		hunk = append(hunk, newLines...)
+
+		diff = append(diff, fmt.Sprintf("@@ -%d,%d +%d,%d @@",
+			start+1, min(end, len(original)-1)-start+1, start+1, min(end, len(sanitized)-1)-start+1))
+		diff = append(diff, hunk...)
+		i = end
+	}
+	return diff
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
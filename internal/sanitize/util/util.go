@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/gob"
+	"io"
 	"os"
 	"strings"
 
@@ -11,14 +12,26 @@ import (
 )
 
 func ReadLinesFromFile(fh *os.File) ([]string, error) {
+	return ReadLines(fh)
+}
+
+// ReadLines reads all the lines from r. The last line is returned even if it doesn't end
+// with a new line.
+func ReadLines(r io.Reader) ([]string, error) {
 	lines := []string{}
-	reader := bufio.NewReader(fh)
+	reader := bufio.NewReader(r)
 
 	line, err := reader.ReadString('\n')
 	for err == nil {
 		lines = append(lines, strings.TrimRight(line, "\n"))
 		line, err = reader.ReadString('\n')
 	}
+	if err != io.EOF {
+		return nil, errors.Wrap(err, "Cannot read lines")
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
 	return lines, nil
 }
 
@@ -0,0 +1,43 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	original := []string{"l1", "l2", "l3", "l4", "l5", "l6", "l7", "l8", "l9", "l10"}
+	change := func(lines ...int) []string {
+		sanitized := append([]string{}, original...)
+		for _, i := range lines {
+			sanitized[i-1] = "s" + sanitized[i-1][1:]
+		}
+		return sanitized
+	}
+
+	tests := []struct {
+		sanitized []string
+		want      []string
+	}{
+		{original, []string{}},
+		{change(5), []string{
+			"--- f (original)", "+++ f (sanitized)",
+			"@@ -4,3 +4,3 @@", " l4", "-l5", "+s5", " l6",
+		}},
+		// Changes closer than twice the context lines are in the same hunk
+		{change(1, 3), []string{
+			"--- f (original)", "+++ f (sanitized)",
+			"@@ -1,4 +1,4 @@", "-l1", "+s1", " l2", "-l3", "+s3", " l4",
+		}},
+		{change(2, 9, 10), []string{
+			"--- f (original)", "+++ f (sanitized)",
+			"@@ -1,3 +1,3 @@", " l1", "-l2", "+s2", " l3",
+			"@@ -8,3 +8,3 @@", " l8", "-l9", "-l10", "+s9", "+s10",
+		}},
+	}
+	for i, test := range tests {
+		if got := UnifiedDiff("f", original, test.sanitized, 1); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Test #%d\ngot:  %q\nwant: %q", i, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package sanitize
+
+import "regexp"
+
+// UUIDs, like the server UUIDs in GTID sets such as 3E11FA47-71CA-11E1-9E33-C80AA9429562:1-5
+var uuidRE = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+
+// aliasUUIDs replaces the UUIDs in value by UUID aliases. The same UUID gets the same alias
+// in every file, whatever its case, and the transaction ranges of GTID sets are kept so
+// the GTID sets of a primary and its replicas can still be compared.
+func (s *Sanitizer) aliasUUIDs(value string) string {
+	if !s.opts.UUIDs {
+		return value
+	}
+	return uuidRE.ReplaceAllStringFunc(value, func(uuid string) string {
+		return s.replace(RuleUUIDs, uuid, s.alias(KindUUID, uuid))
+	})
+}
@@ -0,0 +1,196 @@
+package sanitize
+
+import (
+	"regexp"
+	"strings"
+)
+
+// How the value of a variable is sanitized
+type variableStrategy int
+
+const (
+	// varGeneric applies the generic rules to the value
+	varGeneric variableStrategy = iota
+	// varKeep keeps the value
+	varKeep
+	// varAlias aliases the host names and IP addresses in the value, like the ones in
+	// wsrep_cluster_address, keeping the ports
+	varAlias
+	// varUUID aliases the value, a server UUID, by another UUID
+	varUUID
+	// varServerID aliases the value, a server id, by another number
+	varServerID
+	// varPath aliases the paths in the value. See sanitizePath.
+	varPath
+	// varQuery sanitizes the value as a query
+	varQuery
+	// varPassword redacts the value, or the password part of user:password values
+	varPassword
+)
+
+var (
+	// Variables that expose the identity of the server, paths, queries or credentials.
+	// Variables not in the list, and not matching variableSuffixes, use varGeneric.
+	variableStrategies = map[string]variableStrategy{
+		"admin_address":                   varAlias,
+		"bind_address":                    varAlias,
+		"group_replication_group_seeds":   varAlias,
+		"group_replication_local_address": varAlias,
+		"hostname":                        varAlias,
+		"mysqlx_bind_address":             varAlias,
+		"report_host":                     varAlias,
+		"wsrep_cluster_address":           varAlias,
+		"wsrep_node_address":              varAlias,
+		"wsrep_node_incoming_address":     varAlias,
+		"wsrep_node_name":                 varAlias,
+		"wsrep_sst_donor":                 varAlias,
+		"wsrep_sst_receive_address":       varAlias,
+
+		"group_replication_group_name": varUUID,
+		"server_uuid":                  varUUID,
+
+		"server_id": varServerID,
+
+		"basedir":                   varPath,
+		"character_sets_dir":        varPath,
+		"datadir":                   varPath,
+		"innodb_data_home_dir":      varPath,
+		"innodb_log_group_home_dir": varPath,
+		"innodb_tmpdir":             varPath,
+		"innodb_undo_directory":     varPath,
+		"keyring_file_data":         varPath,
+		"lc_messages_dir":           varPath,
+		"log_error":                 varPath,
+		"plugin_dir":                varPath,
+		"relay_log":                 varPath,
+		"secure_file_priv":          varPath,
+		"socket":                    varPath,
+		"ssl_ca":                    varPath,
+		"ssl_capath":                varPath,
+		"ssl_cert":                  varPath,
+		"ssl_crl":                   varPath,
+		"ssl_crlpath":               varPath,
+		"ssl_key":                   varPath,
+		"tmpdir":                    varPath,
+		"wsrep_provider_options":    varPath,
+
+		"init_connect": varQuery,
+		"init_replica": varQuery,
+		"init_slave":   varQuery,
+
+		"report_password": varPassword,
+		"wsrep_sst_auth":  varPassword,
+
+		"ft_boolean_syntax":          varKeep,
+		"innodb_data_file_path":      varKeep,
+		"innodb_temp_data_file_path": varKeep,
+		"optimizer_switch":           varKeep,
+		"sql_mode":                   varKeep,
+		"tls_version":                varKeep,
+		"version":                    varKeep,
+		"version_comment":            varKeep,
+	}
+	// Suffixes of the names of the variables whose values are paths, like
+	// slow_query_log_file or log_bin_basename
+	variableSuffixes = []string{"_file", "_dir", "_basename", "_index", "_path"}
+
+	verticalNameRE  = regexp.MustCompile(`^\s*Variable_name: (.*)$`)
+	verticalValueRE = regexp.MustCompile(`^(\s*Value: )(.*)$`)
+	tableRowRE      = regexp.MustCompile(`^(\|\s*)(\S+)(\s*\|\s)(.*?)(\s*\|)$`)
+)
+
+// sanitizeVariablesFile sanitizes the output of SHOW VARIABLES, in the tab separated,
+// table and vertical (\G) formats, using the strategy of each variable
+func (s *Sanitizer) sanitizeVariablesFile(lines []string) []string {
+	name := ""
+	for i, line := range lines {
+		s.line++
+		if m := verticalNameRE.FindStringSubmatch(line); m != nil {
+			name = m[1]
+			continue
+		}
+		if m := verticalValueRE.FindStringSubmatch(line); m != nil && name != "" {
+			lines[i] = m[1] + s.sanitizeVariable(name, m[2])
+			name = ""
+			continue
+		}
+		if fields := strings.SplitN(line, "\t", 2); len(fields) == 2 {
+			lines[i] = fields[0] + "\t" + s.sanitizeVariable(fields[0], fields[1])
+			continue
+		}
+		if m := tableRowRE.FindStringSubmatch(line); m != nil {
+			value := s.sanitizeVariable(m[2], m[4])
+			// Keep the table borders aligned if possible
+			spaces := len(m[5]) - 1 + len(m[4]) - len(value)
+			if spaces < 1 {
+				spaces = 1
+			}
+			lines[i] = m[1] + m[2] + m[3] + value + strings.Repeat(" ", spaces) + "|"
+			continue
+		}
+		lines[i] = s.sanitizeLine(line)
+	}
+	return lines
+}
+
+func variableStrategyOf(name string) variableStrategy {
+	name = strings.ToLower(name)
+	if strategy, ok := variableStrategies[name]; ok {
+		return strategy
+	}
+	for _, suffix := range variableSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return varPath
+		}
+	}
+	return varGeneric
+}
+
+// sanitizeVariable sanitizes the value of a variable. The rules used by its strategy must
+// be enabled; a disabled rule keeps the value.
+func (s *Sanitizer) sanitizeVariable(name, value string) string {
+	if value == "" || value == "NULL" {
+		return value
+	}
+	switch variableStrategyOf(name) {
+	case varKeep:
+		return value
+	case varAlias:
+		return s.aliasAddresses(value)
+	case varUUID:
+		return s.aliasUUIDs(value)
+	case varServerID:
+		return s.aliasServerID(value)
+	case varPath:
+		return s.sanitizeLine(s.sanitizePaths(value))
+	case varQuery:
+		if !s.opts.Queries && !s.opts.Identifiers {
+			return value
+		}
+		return s.sanitizeQuery(value)
+	case varPassword:
+		if !s.opts.Credentials {
+			return value
+		}
+		if i := strings.Index(value, ":"); i >= 0 {
+			return value[:i+1] + s.replace(RuleCredentials, value[i+1:], RedactedPassword)
+		}
+		return s.replace(RuleCredentials, value, RedactedPassword)
+	}
+	return s.sanitizeLine(value)
+}
+
+// aliasAddresses aliases the hosts of a comma separated list of addresses, like
+// gcomm://10.0.0.1:4567,db2 or db1:33061, keeping the schemes and ports
+func (s *Sanitizer) aliasAddresses(value string) string {
+	scheme := ""
+	if i := strings.Index(value, "://"); i >= 0 {
+		scheme, value = value[:i+3], value[i+3:]
+	}
+	addresses := strings.Split(value, ",")
+	for i, address := range addresses {
+		host, port := splitHostPort(strings.TrimSpace(address))
+		addresses[i] = strings.Replace(address, host+port, s.aliasEndpointHost(host)+port, 1)
+	}
+	return scheme + strings.Join(addresses, ",")
+}
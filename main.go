@@ -12,6 +12,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Percona-Lab/sanitizer/internal/sanitize"
 	"github.com/Sirupsen/logrus"
 	log "github.com/Sirupsen/logrus"
 	"github.com/alecthomas/kingpin"
@@ -49,14 +50,77 @@ type cliOptions struct {
 	NoSanitize          *bool
 	NoSanitizeHostnames *bool
 	NoSanitizeQueries   *bool
+	NoSanitizeCreds     *bool
+	NoSanitizeEmails    *bool
+	NoSanitizeIPs       *bool
+	NoSanitizePaths     *bool
+	NoSanitizeUUIDs     *bool
+	Policy              *string
+	QueryMode           *string
+	StripComments       *bool
+	KeepHints           *bool
+	CommentKeys         *[]string
+	AliasCommentValues  *bool
+	NoSanitizeSecrets   *[]string
+	SanitizeIdentifiers *bool
+	SanitizeUsers       *bool
+	KnownHosts          *[]string
+	InternalSuffixes    *[]string
+	Keep                *[]string
+	RulesFile           *string
+	MappingFile         *string
+	MappingPassword     *string
+	PersistentAliases   *bool
+	AliasKeyFile        *string
 	NoCollect           *bool
 	NoRemoveTempFiles   *bool
+	Review              *bool
+	Scan                *bool
+	Secrets             *[]string
 
 	SanitizeCommand       *kingpin.CmdClause
 	SanitizeInputFile     *string
 	SanitizeOutputFile    *string
 	DontSanitizeHostnames *bool
 	DontSanitizeQueries   *bool
+	DontSanitizeCreds     *bool
+	DontSanitizeEmails    *bool
+	DontSanitizeIPs       *bool
+	DontSanitizePaths     *bool
+	DontSanitizeUUIDs     *bool
+	SanitizePolicy        *string
+	SanitizeQueryMode     *string
+	SanitizeStripComments *bool
+	SanitizeKeepHints     *bool
+	SanitizeCommentKeys   *[]string
+	SanitizeAliasComments *bool
+	DontSanitizeSecrets   *[]string
+	DoSanitizeIdentifiers *bool
+	DoSanitizeUsers       *bool
+	SanitizeKnownHosts    *[]string
+	SanitizeSuffixes      *[]string
+	SanitizeKeep          *[]string
+	SanitizeRulesFile     *string
+	SanitizeMappingFile   *string
+	SanitizeMappingPass   *string
+	SanitizeAliasKeyFile  *string
+	SanitizePersistent    *bool
+	SanitizeFileType      *string
+	SanitizeDryRun        *bool
+	SanitizeReport        *string
+
+	RevealCommand     *kingpin.CmdClause
+	RevealMappingFile *string
+	RevealMappingPass *string
+	RevealInputFile   *string
+	RevealOutputFile  *string
+
+	RotateAliasKeyCommand *kingpin.CmdClause
+	RotateAliasKeyFile    *string
+
+	ScanCommand *kingpin.CmdClause
+	ScanPath    *string
+	ScanSecrets *[]string
 }
 
 type myDefaults struct {
@@ -67,12 +131,18 @@ type myDefaults struct {
 }
 
 const (
-	DecryptCmd       = "decrypt"
-	EncryptCmd       = "encrypt"
-	CollectCmd       = "collect"
-	SanitizeCmd      = "sanitize"
-	DefaultMySQLHost = "127.0.0.1"
-	DefaultMySQLPort = 3306
+	DecryptCmd        = "decrypt"
+	EncryptCmd        = "encrypt"
+	CollectCmd        = "collect"
+	SanitizeCmd       = "sanitize"
+	RevealCmd         = "reveal"
+	RotateAliasKeyCmd = "rotate-alias-key"
+	ScanCmd           = "scan"
+	DefaultMySQLHost  = "127.0.0.1"
+	DefaultMySQLPort  = 3306
+
+	// allSecretTypes disables all the secret types in --no-sanitize-secret
+	allSecretTypes = "all"
 )
 
 var (
@@ -81,6 +151,7 @@ var (
 		"pt-summary",
 		"pt-mysql-summary --host=$mysql-host --port=$mysql-port --user=$mysql-user --password=$mysql-pass",
 	}
+	secretTypeNames = append(append([]string{}, sanitize.SecretTypes...), allSecretTypes)
 )
 
 func main() {
@@ -120,6 +191,12 @@ func main() {
 		err = encryptorCmd(opts)
 	case SanitizeCmd:
 		err = sanitizeFile(opts)
+	case RevealCmd:
+		err = revealFile(opts)
+	case RotateAliasKeyCmd:
+		err = rotateAliasKey(opts)
+	case ScanCmd:
+		err = scanCmd(opts)
 	}
 	if err != nil {
 		log.Fatal(err)
@@ -171,11 +248,14 @@ func processCliParams(baseTempPath string, usageWriter io.Writer) (*cliOptions,
 	}
 
 	opts := &cliOptions{
-		CollectCommand:  app.Command(CollectCmd, "Collect, sanitize, pack and encrypt data from pt-tools."),
-		DecryptCommand:  app.Command(DecryptCmd, "Decrypt an encrypted file. The password will be requested from the terminal."),
-		EncryptCommand:  app.Command(EncryptCmd, "Encrypt a file. The password will be requested from the terminal."),
-		SanitizeCommand: app.Command(SanitizeCmd, "Replace queries in a file by their fingerprints and obfuscate hostnames."),
-		Debug:           app.Flag("debug", "Enable debug log level.").Bool(),
+		CollectCommand:        app.Command(CollectCmd, "Collect, sanitize, pack and encrypt data from pt-tools."),
+		DecryptCommand:        app.Command(DecryptCmd, "Decrypt an encrypted file. The password will be requested from the terminal."),
+		EncryptCommand:        app.Command(EncryptCmd, "Encrypt a file. The password will be requested from the terminal."),
+		SanitizeCommand:       app.Command(SanitizeCmd, "Replace queries in a file by their fingerprints and obfuscate hostnames."),
+		RevealCommand:         app.Command(RevealCmd, "Replace the aliases in a file by the original names using a mapping file. The password will be requested from the terminal."),
+		RotateAliasKeyCommand: app.Command(RotateAliasKeyCmd, "Replace the persistent aliases key. New aliases won't match the ones from previous runs."),
+		ScanCommand:           app.Command(ScanCmd, "Search a directory or a decrypted tar.gz file for data that should have been sanitized."),
+		Debug:                 app.Flag("debug", "Enable debug log level.").Bool(),
 	}
 	// Decrypt command flags
 	opts.DecryptInFile = opts.DecryptCommand.Arg("infile", "Encrypted file.").Required().String()
@@ -207,13 +287,121 @@ func processCliParams(baseTempPath string, usageWriter io.Writer) (*cliOptions,
 	opts.NoEncrypt = opts.CollectCommand.Flag("no-encrypt", "Do not encrypt the output file.").Bool()
 	opts.NoSanitizeHostnames = opts.CollectCommand.Flag("no-sanitize-hostnames", "Don't sanitize host names.").Bool()
 	opts.NoSanitizeQueries = opts.CollectCommand.Flag("no-sanitize-queries", "Do not replace queries by their fingerprints.").Bool()
+	opts.NoSanitizeCreds = opts.CollectCommand.Flag("no-sanitize-credentials", "Don't redact passwords and alias users and hosts "+
+		"in URLs and DSNs.").Bool()
+	opts.NoSanitizeEmails = opts.CollectCommand.Flag("no-sanitize-emails", "Don't replace email addresses by aliases.").Bool()
+	opts.NoSanitizeIPs = opts.CollectCommand.Flag("no-sanitize-ips", "Don't replace IP addresses by aliases.").Bool()
+	opts.NoSanitizePaths = opts.CollectCommand.Flag("no-sanitize-paths", "Don't replace user names and non-standard directory and file names in paths by aliases.").Bool()
+	opts.NoSanitizeUUIDs = opts.CollectCommand.Flag("no-sanitize-uuids", "Don't replace server UUIDs, also in GTID sets, by fake UUIDs, and server ids by other numbers.").Bool()
+	opts.Policy = opts.CollectCommand.Flag("policy", "Sanitization policy: minimal, standard or paranoid. "+
+		"The --no-sanitize-* flags disable rules of the policy.").Default(sanitize.PolicyStandard).Enum(sanitize.Policies...)
+	opts.QueryMode = opts.CollectCommand.Flag("query-mode", "How queries are sanitized: "+
+		strings.Join(sanitize.QueryModes, ", ")+". The default depends on the policy. In digest mode, the queries "+
+		"not found in the collected performance_schema digests get a "+sanitize.DigestFallbackPrefix+" hash of an "+
+		"approximation of their digest text, which is not a MySQL DIGEST.").Enum(sanitize.QueryModes...)
+	opts.StripComments = opts.CollectCommand.Flag("strip-comments", "Remove all the comments of the sanitized queries, "+
+		"including the optimizer hints and the tags kept by the policy.").Bool()
+	opts.KeepHints = opts.CollectCommand.Flag("keep-hints", "Keep the /*+ ... */ optimizer hints of the sanitized queries.").Bool()
+	opts.CommentKeys = opts.CollectCommand.Flag("comment-key", "Keep the sqlcommenter or marginalia tags with this key, "+
+		"like controller, in the comments of the sanitized queries. This parameter can be used more than once.").Strings()
+	opts.AliasCommentValues = opts.CollectCommand.Flag("alias-comment-values", "Replace the values of the kept comment tags by aliases.").Bool()
+	opts.NoSanitizeSecrets = opts.CollectCommand.Flag("no-sanitize-secret", "Don't redact this type of secret: "+
+		strings.Join(secretTypeNames, ", ")+". This parameter can be used more than once.").Enums(secretTypeNames...)
 	opts.NoRemoveTempFiles = opts.CollectCommand.Flag("no-remove-temp-files", "Do not remove temporary files.").Bool()
+	opts.SanitizeIdentifiers = opts.CollectCommand.Flag("sanitize-identifiers",
+		"Replace database, table and column names by aliases like db1.t7.c3.").Bool()
+	opts.SanitizeUsers = opts.CollectCommand.Flag("sanitize-users",
+		"Replace MySQL user names by aliases. System users, like root, are kept.").Bool()
+	opts.KnownHosts = opts.CollectCommand.Flag("known-host", "Host name to replace wherever it appears, in addition to "+
+		"the names of this server. This parameter can be used more than once.").Strings()
+	opts.InternalSuffixes = opts.CollectCommand.Flag("internal-suffix", "Private domain suffix, like corp, used for "+
+		"host names. This parameter can be used more than once.").Default(sanitize.DefaultInternalSuffixes...).Strings()
+	opts.SanitizeKeep = opts.SanitizeCommand.Flag("keep", "Value that must never be replaced, like percona.com or "+
+		"*.cdn.example.com. This parameter can be used more than once.").Strings()
+	opts.SanitizeRulesFile = opts.SanitizeCommand.Flag("rules-file", "Sanitization rules file. "+
+		"Values in its [allowlist] section are never replaced.").String()
+	opts.Keep = opts.CollectCommand.Flag("keep", "Value that must never be replaced, like percona.com or "+
+		"*.cdn.example.com. This parameter can be used more than once.").Strings()
+	opts.RulesFile = opts.CollectCommand.Flag("rules-file", "Sanitization rules file. "+
+		"Values in its [allowlist] section are never replaced.").String()
+	opts.MappingFile = opts.CollectCommand.Flag("mapping-file", "Write the aliases mapping into this file, encrypted with the "+
+		"mapping password. This file is never included in the output tar file.").String()
+	opts.MappingPassword = opts.CollectCommand.Flag("mapping-password", "Encrypt the mapping file using this password. "+
+		"It must be different from the encryption password. If omitted, it will be requested from the terminal.").String()
+	opts.Review = opts.CollectCommand.Flag("review", "Show the differences between the original and the sanitized files "+
+		"and ask for confirmation before creating the output file.").Bool()
+	opts.Scan = opts.CollectCommand.Flag("scan", "Scan the output file for possible leaks before encrypting it. "+
+		"The process stops if something is found.").Bool()
+	opts.Secrets = opts.CollectCommand.Flag("secret", "Value that must not be present in the output file. "+
+		"This parameter can be used more than once. The MySQL password is always included.").Strings()
+	opts.PersistentAliases = opts.CollectCommand.Flag("persistent-aliases", "Derive aliases from a locally stored key "+
+		"so the same names get the same aliases in every run.").Bool()
+	opts.AliasKeyFile = opts.CollectCommand.Flag("alias-key-file", "Key file used for persistent aliases. "+
+		"It is created if it doesn't exist.").Default(DefaultAliasKeyFile).String()
 
 	// Sanitize command flags
 	opts.SanitizeInputFile = opts.SanitizeCommand.Flag("input-file", "Input file. If not specified, the input will be Stdin.").String()
 	opts.SanitizeOutputFile = opts.SanitizeCommand.Flag("output-file", "Output file. If not specified, the input will be Stdout.").String()
+	opts.SanitizeFileType = opts.SanitizeCommand.Flag("file-type", "Type of the input file: "+strings.Join(sanitize.FileTypes, ", ")+
+		". If not specified, it is detected using the file name and its content.").Enum(sanitize.FileTypes...)
 	opts.DontSanitizeHostnames = opts.SanitizeCommand.Flag("no-sanitize-hostnames", "Don't sanitize host names.").Bool()
 	opts.DontSanitizeQueries = opts.SanitizeCommand.Flag("no-sanitize-queries", "Don't replace queries by their fingerprints.").Bool()
+	opts.DontSanitizeCreds = opts.SanitizeCommand.Flag("no-sanitize-credentials", "Don't redact passwords and alias users and hosts "+
+		"in URLs and DSNs.").Bool()
+	opts.DontSanitizeEmails = opts.SanitizeCommand.Flag("no-sanitize-emails", "Don't replace email addresses by aliases.").Bool()
+	opts.DontSanitizeIPs = opts.SanitizeCommand.Flag("no-sanitize-ips", "Don't replace IP addresses by aliases.").Bool()
+	opts.DontSanitizePaths = opts.SanitizeCommand.Flag("no-sanitize-paths", "Don't replace user names and non-standard directory and file names in paths by aliases.").Bool()
+	opts.DontSanitizeUUIDs = opts.SanitizeCommand.Flag("no-sanitize-uuids", "Don't replace server UUIDs, also in GTID sets, by fake UUIDs, and server ids by other numbers.").Bool()
+	opts.SanitizePolicy = opts.SanitizeCommand.Flag("policy", "Sanitization policy: minimal, standard or paranoid. "+
+		"The --no-sanitize-* flags disable rules of the policy.").Default(sanitize.PolicyStandard).Enum(sanitize.Policies...)
+	opts.SanitizeQueryMode = opts.SanitizeCommand.Flag("query-mode", "How queries are sanitized: "+
+		strings.Join(sanitize.QueryModes, ", ")+". The default depends on the policy. In digest mode, the queries "+
+		"not found in the collected performance_schema digests get a "+sanitize.DigestFallbackPrefix+" hash of an "+
+		"approximation of their digest text, which is not a MySQL DIGEST.").Enum(sanitize.QueryModes...)
+	opts.SanitizeStripComments = opts.SanitizeCommand.Flag("strip-comments", "Remove all the comments of the sanitized queries, "+
+		"including the optimizer hints and the tags kept by the policy.").Bool()
+	opts.SanitizeKeepHints = opts.SanitizeCommand.Flag("keep-hints", "Keep the /*+ ... */ optimizer hints of the sanitized queries.").Bool()
+	opts.SanitizeCommentKeys = opts.SanitizeCommand.Flag("comment-key", "Keep the sqlcommenter or marginalia tags with this key, "+
+		"like controller, in the comments of the sanitized queries. This parameter can be used more than once.").Strings()
+	opts.SanitizeAliasComments = opts.SanitizeCommand.Flag("alias-comment-values", "Replace the values of the kept comment tags by aliases.").Bool()
+	opts.DontSanitizeSecrets = opts.SanitizeCommand.Flag("no-sanitize-secret", "Don't redact this type of secret: "+
+		strings.Join(secretTypeNames, ", ")+". This parameter can be used more than once.").Enums(secretTypeNames...)
+	opts.DoSanitizeIdentifiers = opts.SanitizeCommand.Flag("sanitize-identifiers",
+		"Replace database, table and column names by aliases like db1.t7.c3.").Bool()
+	opts.DoSanitizeUsers = opts.SanitizeCommand.Flag("sanitize-users",
+		"Replace MySQL user names by aliases. System users, like root, are kept.").Bool()
+	opts.SanitizeKnownHosts = opts.SanitizeCommand.Flag("known-host", "Host name to replace wherever it appears, "+
+		"even if it doesn't look like a host name. This parameter can be used more than once.").Strings()
+	opts.SanitizeSuffixes = opts.SanitizeCommand.Flag("internal-suffix", "Private domain suffix, like corp, used for "+
+		"host names. This parameter can be used more than once.").Default(sanitize.DefaultInternalSuffixes...).Strings()
+	opts.SanitizeMappingFile = opts.SanitizeCommand.Flag("mapping-file", "Write the aliases mapping into this file, "+
+		"encrypted with the mapping password.").String()
+	opts.SanitizeMappingPass = opts.SanitizeCommand.Flag("mapping-password", "Encrypt the mapping file using this password. "+
+		"If omitted, it will be requested from the terminal.").String()
+	opts.SanitizeDryRun = opts.SanitizeCommand.Flag("dry-run", "Do not write any output file. "+
+		"Show the list of replacements that would be made instead.").Bool()
+	opts.SanitizeReport = opts.SanitizeCommand.Flag("report", "Format of the --dry-run report: text or json. "+
+		"Original values are only shown if the output is a terminal.").Default(ReportText).Enum(ReportText, ReportJSON)
+	opts.SanitizePersistent = opts.SanitizeCommand.Flag("persistent-aliases", "Derive aliases from a locally stored key "+
+		"so the same names get the same aliases in every run.").Bool()
+	opts.SanitizeAliasKeyFile = opts.SanitizeCommand.Flag("alias-key-file", "Key file used for persistent aliases. "+
+		"It is created if it doesn't exist, except with --dry-run.").Default(DefaultAliasKeyFile).String()
+
+	// Reveal command flags
+	opts.RevealMappingFile = opts.RevealCommand.Arg("mapping-file", "Encrypted mapping file written by collect or sanitize.").Required().String()
+	opts.RevealMappingPass = opts.RevealCommand.Flag("mapping-password", "Password of the mapping file. "+
+		"If omitted, it will be requested from the terminal.").String()
+	opts.RevealInputFile = opts.RevealCommand.Flag("input-file", "Input file. If not specified, the input will be Stdin.").String()
+	opts.RevealOutputFile = opts.RevealCommand.Flag("output-file", "Output file. If not specified, the input will be Stdout.").String()
+
+	// Scan command flags
+	opts.ScanPath = opts.ScanCommand.Arg("path", "Directory or tar.gz file to scan.").Required().String()
+	opts.ScanSecrets = opts.ScanCommand.Flag("secret", "Value that must not be present in the scanned files. "+
+		"This parameter can be used more than once.").Strings()
+
+	// Rotate alias key command flags
+	opts.RotateAliasKeyFile = opts.RotateAliasKeyCommand.Flag("alias-key-file", "Key file used for persistent aliases.").
+		Default(DefaultAliasKeyFile).String()
 
 	opts.Command, err = app.Parse(os.Args[1:])
 	if err != nil {
@@ -227,16 +415,26 @@ func processCliParams(baseTempPath string, usageWriter io.Writer) (*cliOptions,
 	*opts.BinDir = expandHomeDir(*opts.BinDir)
 	*opts.ConfigFile = expandHomeDir(*opts.ConfigFile)
 	*opts.TempDir = expandHomeDir(*opts.TempDir)
-	for _, incDir := range *opts.IncludeDirs {
-		incDir = expandHomeDir(incDir)
+	*opts.MappingFile = expandHomeDir(*opts.MappingFile)
+	*opts.SanitizeMappingFile = expandHomeDir(*opts.SanitizeMappingFile)
+	*opts.RevealMappingFile = expandHomeDir(*opts.RevealMappingFile)
+	*opts.AliasKeyFile = expandHomeDir(*opts.AliasKeyFile)
+	*opts.SanitizeAliasKeyFile = expandHomeDir(*opts.SanitizeAliasKeyFile)
+	*opts.RotateAliasKeyFile = expandHomeDir(*opts.RotateAliasKeyFile)
+	*opts.ScanPath = expandHomeDir(*opts.ScanPath)
+	*opts.RulesFile = expandHomeDir(*opts.RulesFile)
+	*opts.SanitizeRulesFile = expandHomeDir(*opts.SanitizeRulesFile)
+	for i, incDir := range *opts.IncludeDirs {
+		(*opts.IncludeDirs)[i] = expandHomeDir(incDir)
 	}
 
 	if *opts.BinDir != "" {
 		os.Setenv("PATH", fmt.Sprintf("%s%s%s", *opts.BinDir, string(os.PathListSeparator), os.Getenv("PATH")))
 	}
 
-	lp, err := exec.LookPath("pt-summary")
-	if (err != nil || lp == "") && *opts.BinDir == "" && opts.Command == "collect" && !*opts.NoCollect {
+	// Don't reuse err here. Only the collect command needs the toolkit.
+	lp, lookErr := exec.LookPath("pt-summary")
+	if (lookErr != nil || lp == "") && *opts.BinDir == "" && opts.Command == "collect" && !*opts.NoCollect {
 		return nil, errors.New("Cannot find Percona Toolkit binaries. Please run this tool again using --bin-dir parameter")
 	}
 
@@ -253,9 +451,23 @@ func processCliParams(baseTempPath string, usageWriter io.Writer) (*cliOptions,
 				return nil, err
 			}
 		}
-		err = askEncryptionPassword(opts, true)
+		if err = validateMappingFile(*opts.MappingFile, *opts.TempDir, *opts.IncludeDirs); err != nil {
+			return nil, err
+		}
+		if err = askEncryptionPassword(opts, true); err != nil {
+			return nil, err
+		}
+		if *opts.MappingFile != "" && !*opts.NoSanitize {
+			err = askMappingPassword(opts.MappingPassword, *opts.EncryptPassword, true)
+		}
 	case EncryptCmd:
 		err = askEncryptionPassword(opts, true)
+	case SanitizeCmd:
+		if *opts.SanitizeMappingFile != "" && !*opts.SanitizeDryRun {
+			err = askMappingPassword(opts.SanitizeMappingPass, "", true)
+		}
+	case RevealCmd:
+		err = askMappingPassword(opts.RevealMappingPass, "", false)
 	case DecryptCmd:
 		if !strings.HasSuffix(*opts.DecryptInFile, ".aes") && *opts.DecryptOutFile == "" {
 			return nil, fmt.Errorf("Input file does not have .aes extension. I cannot infer the output file")
@@ -316,7 +528,7 @@ func askMySQLPassword(opts *cliOptions) error {
 }
 
 func askEncryptionPassword(opts *cliOptions, requireConfirmation bool) error {
-	if !*opts.NoEncrypt && *opts.EncryptPassword == "" {
+	if needsEncryptionPassword(opts) && *opts.EncryptPassword == "" {
 		fmt.Print("Encryption password: ")
 		passa, err := terminal.ReadPassword(0)
 		if err != nil {
@@ -339,6 +551,73 @@ func askEncryptionPassword(opts *cliOptions, requireConfirmation bool) error {
 	return nil
 }
 
+// askMappingPassword asks for the mapping file password if it was not given in the command
+// line. The mapping file reverts the sanitization so its password cannot be the same one
+// used to encrypt the output file, that is given to support.
+func askMappingPassword(password *string, encryptPassword string, requireConfirmation bool) error {
+	if *password == "" {
+		fmt.Print("Mapping file password: ")
+		passa, err := terminal.ReadPassword(0)
+		if err != nil {
+			return errors.Wrap(err, "Cannot read mapping file password from the terminal")
+		}
+		fmt.Println("")
+		if requireConfirmation {
+			fmt.Print("Re type password: ")
+			passb, err := terminal.ReadPassword(0)
+			if err != nil {
+				return errors.Wrap(err, "Cannot read mapping file password confirmation from the terminal")
+			}
+			fmt.Println("")
+			if string(passa) != string(passb) {
+				return errors.New("Passwords don't match")
+			}
+		}
+		*password = string(passa)
+	}
+	if *password == "" {
+		return errors.New("The mapping file password cannot be empty")
+	}
+	if *password == encryptPassword {
+		return errors.New("The mapping file password must be different from the encryption password")
+	}
+	return nil
+}
+
+// needsEncryptionPassword returns true if the command is going to encrypt or decrypt a file
+func needsEncryptionPassword(opts *cliOptions) bool {
+	switch opts.Command {
+	case CollectCmd:
+		return !*opts.NoEncrypt
+	case SanitizeCmd, RevealCmd, RotateAliasKeyCmd, ScanCmd:
+		return false
+	}
+	return true
+}
+
+// validateMappingFile checks the mapping file is not going to be included in the output tar
+// file since it can be used to revert the sanitization.
+func validateMappingFile(mappingFile, tempDir string, includeDirs []string) error {
+	if mappingFile == "" {
+		return nil
+	}
+	mappingFile, err := filepath.Abs(mappingFile)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot get the absolute path of %q", mappingFile)
+	}
+	for _, dir := range append([]string{tempDir}, includeDirs...) {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return errors.Wrapf(err, "Cannot get the absolute path of %q", dir)
+		}
+		rel, err := filepath.Rel(absDir, mappingFile)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("The mapping file cannot be in %q because it would be included in the output file", dir)
+		}
+	}
+	return nil
+}
+
 func getParamsFromMyCnf(configFile string) (*myDefaults, error) {
 	log.Debugf("Reading default MySQL parameters from config file: %q", configFile)
 	if configFile == "" {
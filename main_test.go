@@ -3,9 +3,15 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/json"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+
+	"github.com/Percona-Lab/sanitizer/internal/sanitize"
 )
 
 func TestProcessCliParams(t *testing.T) {
@@ -37,3 +43,571 @@ func TestProcessCliParams(t *testing.T) {
 	}
 
 }
+
+func TestMappingFileRoundTrip(t *testing.T) {
+	aliases := sanitize.NewAliases()
+	aliases.Get(sanitize.KindHost, "db1.example.com")
+	aliases.Get(sanitize.KindUser, "bob")
+	filename := filepath.Join(t.TempDir(), "mapping.aes")
+
+	if err := writeMappingFile(filename, aliases, "mapping-secret"); err != nil {
+		t.Fatalf("Cannot write the mapping file: %s", err)
+	}
+	mapping, err := readMappingFile(filename, "mapping-secret")
+	if err != nil {
+		t.Fatalf("Cannot read the mapping file: %s", err)
+	}
+	if !reflect.DeepEqual(mapping, aliases.Mapping()) {
+		t.Errorf("Invalid mapping\ngot:  %v\nwant: %v", mapping, aliases.Mapping())
+	}
+
+	if _, err = readMappingFile(filename, "bundle-secret"); err == nil {
+		t.Errorf("Reading the mapping file with a wrong password must fail")
+	}
+
+	// A random salt and nonce are used so the same data never produces the same ciphertext
+	first, _ := ioutil.ReadFile(filename)
+	if err = writeMappingFile(filename, aliases, "mapping-secret"); err != nil {
+		t.Fatalf("Cannot write the mapping file: %s", err)
+	}
+	second, _ := ioutil.ReadFile(filename)
+	if bytes.Equal(first, second) {
+		t.Errorf("The mapping file was encrypted twice into the same ciphertext")
+	}
+	if bytes.Contains(first, []byte("db1.example.com")) {
+		t.Errorf("The mapping file is not encrypted")
+	}
+	if bytes.Equal(first[:scryptSaltSize], second[:scryptSaltSize]) {
+		t.Errorf("The mapping file was encrypted twice with the same salt")
+	}
+
+	// Altered files, including their salt, are rejected by the authenticated encryption
+	for _, pos := range []int{0, len(first) - 1} {
+		altered := append([]byte{}, first...)
+		altered[pos] ^= 0xff
+		if err = ioutil.WriteFile(filename, altered, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = readMappingFile(filename, "mapping-secret"); err == nil {
+			t.Errorf("Reading a mapping file altered at byte %d must fail", pos)
+		}
+	}
+}
+
+func TestRevealFile(t *testing.T) {
+	dir := t.TempDir()
+	aliases := sanitize.NewAliases()
+	s := sanitize.New(sanitize.Options{Hostnames: true, IPs: true}, aliases)
+	sanitized := s.Sanitize([]string{"Connected to db1.example.com from 10.1.2.3"})
+
+	mappingFile := filepath.Join(dir, "mapping.aes")
+	if err := writeMappingFile(mappingFile, aliases, "mapping-secret"); err != nil {
+		t.Fatalf("Cannot write the mapping file: %s", err)
+	}
+	writeTestFiles(t, dir, map[string]string{"report.txt": strings.Join(sanitized, "\n") + "\n"})
+	inputFile := filepath.Join(dir, "report.txt")
+	outputFile := filepath.Join(dir, "revealed.txt")
+
+	opts := &cliOptions{
+		RevealMappingFile: &mappingFile,
+		RevealMappingPass: new(string),
+		RevealInputFile:   &inputFile,
+		RevealOutputFile:  &outputFile,
+	}
+	*opts.RevealMappingPass = "mapping-secret"
+	if err := revealFile(opts); err != nil {
+		t.Fatalf("Cannot reveal %q: %s", inputFile, err)
+	}
+	got, _ := ioutil.ReadFile(outputFile)
+	if want := "Connected to db1.example.com from 10.1.2.3\n"; string(got) != want {
+		t.Errorf("got: %q, want: %q", string(got), want)
+	}
+}
+
+func TestAskMappingPassword(t *testing.T) {
+	tests := []struct {
+		Password        string
+		EncryptPassword string
+		WantErr         bool
+	}{
+		{"mapping-secret", "bundle-secret", false},
+		{"mapping-secret", "", false},
+		{"bundle-secret", "bundle-secret", true},
+	}
+
+	for i, test := range tests {
+		password := test.Password
+		err := askMappingPassword(&password, test.EncryptPassword, true)
+		if (err != nil) != test.WantErr {
+			t.Errorf("Test #%d got error %v, want error: %v", i, err, test.WantErr)
+		}
+	}
+}
+
+func TestValidateMappingFile(t *testing.T) {
+	tests := []struct {
+		MappingFile string
+		WantErr     bool
+	}{
+		{"", false},
+		{"/home/karl/mapping.aes", false},
+		{"/tmp/data_collection/mapping.aes", true},
+		{"/tmp/data_collection/sub/mapping.aes", true},
+		{"/tmp/data_collection_old/mapping.aes", false},
+		{"/var/log/mysql/mapping.aes", true},
+		{"/var/log/mysql/../mapping.aes", false},
+		{"/etc/mysql/conf.d/mapping.aes", true},
+	}
+
+	for i, test := range tests {
+		err := validateMappingFile(test.MappingFile, "/tmp/data_collection", []string{"/var/log/mysql", "/etc/mysql"})
+		if (err != nil) != test.WantErr {
+			t.Errorf("Test #%d validateMappingFile(%q) got error %v, want error: %v", i, test.MappingFile, err, test.WantErr)
+		}
+	}
+}
+
+func TestScanPath(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"clean/processlist": "Info: select c1 from t1 where c2 = ?\n",
+		"clean/binlog":      "SET TIMESTAMP=1520256301/*!*/;\n",
+		"leaky/processlist": "Info: SELECT name FROM customers WHERE id = 42\nHost: 10.1.2.3\npassword hunter2\n",
+	})
+	if err := tarit(filepath.Join(dir, "leaky.tar.gz"), []string{filepath.Join(dir, "leaky")}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		Path string
+		Want []string
+	}{
+		{"clean", nil},
+		{"leaky", []string{
+			"/processlist:1: sql-literal: **",
+			"/processlist:2: ip: ********",
+			"/processlist:3: secret: *******",
+		}},
+		{"leaky.tar.gz", []string{
+			":leaky/processlist:1: sql-literal: **",
+			":leaky/processlist:2: ip: ********",
+			":leaky/processlist:3: secret: *******",
+		}},
+	}
+	for i, test := range tests {
+		path := filepath.Join(dir, test.Path)
+		buf := &bytes.Buffer{}
+		err := scanPath(path, []string{"hunter2"}, buf)
+		if (err != nil) != (test.Want != nil) {
+			t.Errorf("Test #%d %s: got error %v, want error: %v", i, test.Path, err, test.Want != nil)
+		}
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line != "" {
+				got = append(got, strings.TrimPrefix(line, path))
+			}
+		}
+		if !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("Test #%d %s\ngot:  %q\nwant: %q", i, test.Path, got, test.Want)
+		}
+	}
+}
+
+func TestKnownHostnames(t *testing.T) {
+	got := knownHostnames([]string{
+		"db-prod-07.example.com",
+		"DB-PROD-07",
+		"mysql",
+		"db",
+		"db1",
+		"master",
+		"web01",
+		"mysql.example.com",
+		"localhost",
+		" ",
+	})
+	want := []string{"db-prod-07.example.com", "db-prod-07", "web01", "mysql.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestEtcHostsNames(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"hosts": "127.0.0.1\tlocalhost mysql\n" +
+			"127.0.1.1\tdb-prod-07.example.com db-prod-07 # this server\n" +
+			"10.0.0.7\tdb-prod-08 db\n" +
+			"10.0.0.9\tweb01 app\n" +
+			"# 10.0.0.10\tdb-old\n",
+	})
+
+	got := etcHostsNames(filepath.Join(dir, "hosts"), []string{"DB-PROD-08"})
+	want := []string{"localhost", "mysql", "db-prod-07.example.com", "db-prod-07", "db-prod-08", "db"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("etcHostsNames\ngot:  %v\nwant: %v", got, want)
+	}
+	// The common names given to this server are not replaced everywhere
+	want = []string{"db-prod-07.example.com", "db-prod-07", "db-prod-08"}
+	if known := knownHostnames(got); !reflect.DeepEqual(known, want) {
+		t.Errorf("knownHostnames\ngot:  %v\nwant: %v", known, want)
+	}
+}
+
+func TestCollectedHostnames(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"2018_03_05_13_24_55-hostname":  "db-prod-07\n",
+		"2018_03_05_13_24_55-variables": "| hostname | db-prod-07.example.com |\nreport_host\tdb-replica-2\n| max_connections | 151 |\n",
+	})
+
+	tests := []struct {
+		File string
+		Want []string
+	}{
+		{"2018_03_05_13_24_55-hostname", []string{"db-prod-07"}},
+		{"2018_03_05_13_24_55-variables", []string{"db-prod-07.example.com", "db-replica-2"}},
+	}
+	for i, test := range tests {
+		if got := collectedHostnames(filepath.Join(dir, test.File)); !reflect.DeepEqual(got, test.Want) {
+			t.Errorf("Test #%d %s\ngot:  %v\nwant: %v", i, test.File, got, test.Want)
+		}
+	}
+}
+
+func TestPersistentAliases(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), strings.TrimPrefix(DefaultAliasKeyFile, "~/"))
+
+	// The key file and its directory are created on the first run, only readable by the user
+	first, err := newAliases(true, keyFile, false)
+	if err != nil {
+		t.Fatalf("Cannot create the aliases: %s", err)
+	}
+	for file, perm := range map[string]os.FileMode{filepath.Dir(keyFile): 0700, keyFile: 0600} {
+		fi, err := os.Stat(file)
+		if err != nil {
+			t.Fatalf("Cannot stat %q: %s", file, err)
+		}
+		if fi.Mode().Perm() != perm {
+			t.Errorf("Invalid permissions for %q: got %v, want %v", file, fi.Mode().Perm(), perm)
+		}
+	}
+
+	values := []struct {
+		Kind  string
+		Value string
+	}{
+		{sanitize.KindHost, "db-prod-07.example.com"},
+		{sanitize.KindUser, "karl"},
+		{sanitize.KindIP, "10.1.2.3"},
+	}
+	aliases := func(a *sanitize.Aliases) []string {
+		got := []string{}
+		for _, v := range values {
+			got = append(got, a.Get(v.Kind, v.Value))
+		}
+		return got
+	}
+	want := aliases(first)
+
+	// Another run with the same key gets the same aliases, whatever the order of the values
+	second, err := newAliases(true, keyFile, false)
+	if err != nil {
+		t.Fatalf("Cannot load the aliases key: %s", err)
+	}
+	second.Get(sanitize.KindHost, "app7.corp")
+	if got := aliases(second); !reflect.DeepEqual(got, want) {
+		t.Errorf("Aliases with the same key\ngot:  %v\nwant: %v", got, want)
+	}
+
+	// The aliases change after rotating the key
+	if err := rotateAliasKey(&cliOptions{RotateAliasKeyFile: &keyFile}); err != nil {
+		t.Fatalf("Cannot rotate the aliases key: %s", err)
+	}
+	rotated, err := newAliases(true, keyFile, false)
+	if err != nil {
+		t.Fatalf("Cannot load the rotated aliases key: %s", err)
+	}
+	got := aliases(rotated)
+	for i := range got {
+		if got[i] == want[i] {
+			t.Errorf("Alias of %q not changed by the key rotation: %q", values[i].Value, got[i])
+		}
+	}
+
+	if err := ioutil.WriteFile(keyFile, []byte("not a key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newAliases(true, keyFile, false); err == nil {
+		t.Errorf("No error for an invalid key file")
+	}
+}
+
+func TestDryRunAliasKey(t *testing.T) {
+	dir := t.TempDir()
+	inputFile := filepath.Join(dir, "processlist.txt")
+	keyFile := filepath.Join(dir, strings.TrimPrefix(DefaultAliasKeyFile, "~/"))
+	if err := ioutil.WriteFile(inputFile, []byte("Connected to db-prod-07.example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Args = []string{"pt-sanitize-data", "sanitize", "--input-file", inputFile, "--dry-run", "--report", ReportJSON,
+		"--persistent-aliases", "--alias-key-file", keyFile}
+	opts, err := processCliParams(dir, nil)
+	if err != nil {
+		t.Fatalf("Cannot parse the command line: %s", err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout, err = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sanitizeFile(opts)
+	os.Stdout.Close()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("Cannot sanitize %q: %s", inputFile, err)
+	}
+
+	// A dry run never writes any file, so a temporary key is used for the persistent aliases
+	if _, err := os.Stat(filepath.Dir(keyFile)); !os.IsNotExist(err) {
+		t.Errorf("The dry run created the aliases key directory %q", filepath.Dir(keyFile))
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Errorf("The dry run wrote %d files, want only the input file", len(files)-1)
+	}
+}
+
+func TestWriteMatchesReport(t *testing.T) {
+	opts, _ := sanitize.PolicyOptions(sanitize.PolicyStandard)
+	s := sanitize.New(opts, nil)
+	matches := func() []sanitize.Match {
+		return s.Matches(sanitize.FileGeneric, []string{
+			"Connected to db-prod-07.example.com from 10.1.2.3",
+			"Info: SELECT name FROM customers WHERE email = 'alice@example.com'",
+			"Host: 10.1.2.4",
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeMatchesReport(buf, ReportText, "processlist", matches()); err != nil {
+		t.Fatalf("Cannot write the text report: %s", err)
+	}
+	report := buf.String()
+	for _, original := range []string{"db-prod-07", "10.1.2.3", "10.1.2.4", "alice"} {
+		if strings.Contains(report, original) {
+			t.Errorf("The report shows %q when the output is not a terminal:\n%s", original, report)
+		}
+	}
+	for _, want := range []string{
+		"processlist:1 [hostnames] -> \"host-0001\"\n",
+		"processlist:3 [ips] -> \"ip-0002\"\n",
+		"  hostnames:   1\n",
+		"  ips:         2\n",
+		"  queries:     1\n",
+		"  total:       4\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("The report does not contain %q:\n%s", want, report)
+		}
+	}
+
+	buf.Reset()
+	if err := writeMatchesReport(buf, ReportJSON, "processlist", matches()); err != nil {
+		t.Fatalf("Cannot write the JSON report: %s", err)
+	}
+	var got matchesReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Invalid JSON report: %s\n%s", err, buf.String())
+	}
+	want := map[string]int{"hostnames": 1, "ips": 2, "queries": 1}
+	if !reflect.DeepEqual(got.Counts, want) {
+		t.Errorf("Counts\ngot:  %v\nwant: %v", got.Counts, want)
+	}
+	for _, m := range got.Matches {
+		if m.Original != "" {
+			t.Errorf("The JSON report shows %q when the output is not a terminal", m.Original)
+		}
+	}
+}
+
+func TestReviewFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	newFiles := func() []processedFile {
+		return []processedFile{
+			{
+				Name:      "processlist",
+				Outfile:   filepath.Join(dir, "processlist"),
+				Original:  []string{"Host: db-prod-07.example.com", "User: acme_app", "Info: NULL"},
+				Sanitized: []string{"Host: host-0001", "User: acme_app", "Info: NULL"},
+			},
+			{
+				Name:      "variables",
+				Outfile:   filepath.Join(dir, "variables"),
+				Original:  []string{"max_connections\t151", "init_connect\tSET @app='acme'"},
+				Sanitized: []string{"max_connections\t151", "init_connect\tSET @app='acme'"},
+			},
+			{
+				Name:      "df",
+				Outfile:   filepath.Join(dir, "df"),
+				Original:  []string{"/dev/sda1 50% /"},
+				Sanitized: []string{"/dev/sda1 50% /"},
+			},
+		}
+	}
+
+	files := newFiles()
+	out := &bytes.Buffer{}
+	if err := reviewFiles(files, strings.NewReader("acme\n\ny\n"), out); err != nil {
+		t.Fatalf("Review failed: %s\n%s", err, out.String())
+	}
+	for _, want := range []string{
+		"--- processlist (original)\n+++ processlist (sanitized)\n@@ -1,3 +1,3 @@\n" +
+			"-Host: db-prod-07.example.com\n+Host: host-0001\n User: acme_app\n Info: NULL\n",
+		"variables: no changes\n",
+		"df: no changes\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("The review output does not contain %q:\n%s", want, out.String())
+		}
+	}
+
+	// The extra string is redacted in all the files, and only the changed files are rewritten
+	want := map[string][]string{
+		"processlist": {"Host: host-0001", "User: <redacted>_app", "Info: NULL"},
+		"variables":   {"max_connections\t151", "init_connect\tSET @app='<redacted>'"},
+	}
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file.Outfile)
+		if _, ok := want[file.Name]; !ok {
+			if !os.IsNotExist(err) {
+				t.Errorf("Unchanged file %q was rewritten", file.Name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Cannot read %q: %s", file.Outfile, err)
+		}
+		if got := strings.Split(strings.TrimRight(string(buf), "\n"), "\n"); !reflect.DeepEqual(got, want[file.Name]) {
+			t.Errorf("%s\ngot:  %q\nwant: %q", file.Name, got, want[file.Name])
+		}
+	}
+
+	if err := reviewFiles(newFiles(), strings.NewReader("\nn\n"), &bytes.Buffer{}); err == nil {
+		t.Errorf("No error when the review is not confirmed")
+	}
+}
+
+// writeTestFiles writes files, a map of file names to contents, into dir
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestKeepPatterns(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"rules.ini": "[allowlist]\npercona.com\n*.cdn.example.com\n10.0.0.1\n"})
+	rulesFile := filepath.Join(dir, "rules.ini")
+
+	keep, err := keepPatterns(rulesFile, []string{"shop", "karl", "acme"})
+	if err != nil {
+		t.Fatalf("Cannot read the keep patterns: %s", err)
+	}
+	if want := []string{"percona.com", "*.cdn.example.com", "10.0.0.1", "shop", "karl", "acme"}; !reflect.DeepEqual(keep, want) {
+		t.Errorf("Keep patterns\ngot:  %v\nwant: %v", keep, want)
+	}
+	if _, err := keepPatterns("", []string{"[db"}); err == nil {
+		t.Errorf("No error for an invalid keep pattern")
+	}
+
+	// The kept values are not replaced by any rule, even with the paranoid policy
+	tests := []struct {
+		Line string
+		Want string
+	}{
+		{"Connected to www.percona.com and img7.cdn.example.com from db-prod-07.example.com",
+			"Connected to www.percona.com and img7.cdn.example.com from host-0001"},
+		{"Host: 10.0.0.1 10.0.0.2", "Host: 10.0.0.1 ip-0001"},
+		{"Contact alice@percona.com or bob@example.com", "Contact user-0001@percona.com or user-0002@host-0002"},
+		{"           db: shop", "           db: shop"},
+		{"         User: karl", "         User: karl"},
+		{"datadir /data/acme/mysql/ /data/other/mysql/", "datadir /data/acme/mysql/ /data/dir-0001/mysql/"},
+	}
+	opts, _ := sanitize.PolicyOptions(sanitize.PolicyParanoid)
+	opts.Keep = keep
+	s := sanitize.New(opts, nil)
+	for i, test := range tests {
+		if got := s.Sanitize([]string{test.Line}); got[0] != test.Want {
+			t.Errorf("Test #%d\ngot:  %q\nwant: %q", i, got[0], test.Want)
+		}
+	}
+}
+
+func TestSanitizeOptions(t *testing.T) {
+	standardRules := []string{"credentials", "queries", "ips", "hostnames"}
+	tests := []struct {
+		Policy    string
+		Switches  ruleSwitches
+		Rules     []string
+		QueryMode string
+		Secrets   []string
+	}{
+		{sanitize.PolicyMinimal, ruleSwitches{}, []string{"credentials"}, "", sanitize.SecretTypes},
+		// Choosing the query mode enables the queries rule
+		{sanitize.PolicyMinimal, ruleSwitches{QueryMode: sanitize.QueryShape}, []string{"credentials", "queries"},
+			sanitize.QueryShape, sanitize.SecretTypes},
+		{sanitize.PolicyMinimal, ruleSwitches{NoCredentials: true, Users: true}, []string{"users"}, "", sanitize.SecretTypes},
+		{sanitize.PolicyStandard, ruleSwitches{}, standardRules, sanitize.QueryFingerprint, sanitize.SecretTypes},
+		{sanitize.PolicyStandard, ruleSwitches{NoHostnames: true, NoIPs: true},
+			[]string{"credentials", "queries"}, sanitize.QueryFingerprint, sanitize.SecretTypes},
+		{sanitize.PolicyStandard, ruleSwitches{Identifiers: true, Users: true},
+			[]string{"credentials", "queries", "identifiers", "ips", "hostnames", "users"},
+			sanitize.QueryFingerprint, sanitize.SecretTypes},
+		// --no-sanitize-queries wins over --query-mode
+		{sanitize.PolicyStandard, ruleSwitches{NoQueries: true, QueryMode: sanitize.QueryDigest},
+			[]string{"credentials", "ips", "hostnames"}, sanitize.QueryDigest, sanitize.SecretTypes},
+		{sanitize.PolicyStandard, ruleSwitches{NoSecrets: []string{sanitize.SecretJWT, sanitize.SecretHighEntropy}}, standardRules,
+			sanitize.QueryFingerprint, []string{sanitize.SecretPrivateKey, sanitize.SecretAWSKey, sanitize.SecretGitHubToken, sanitize.SecretPassword}},
+		{sanitize.PolicyParanoid, ruleSwitches{},
+			[]string{"credentials", "emails", "queries", "identifiers", "ips", "hostnames", "paths", "uuids", "users"},
+			sanitize.QueryDrop, sanitize.SecretTypes},
+		{sanitize.PolicyParanoid, ruleSwitches{NoCredentials: true, NoEmails: true, NoPaths: true, NoUUIDs: true, NoSecrets: []string{allSecretTypes}},
+			[]string{"queries", "identifiers", "ips", "hostnames", "users"}, sanitize.QueryDrop, []string{}},
+		{sanitize.PolicyParanoid, ruleSwitches{QueryMode: sanitize.QueryFingerprint},
+			[]string{"credentials", "emails", "queries", "identifiers", "ips", "hostnames", "paths", "uuids", "users"},
+			sanitize.QueryFingerprint, sanitize.SecretTypes},
+	}
+
+	for i, test := range tests {
+		opts, err := sanitizeOptions(test.Policy, test.Switches)
+		if err != nil {
+			t.Fatalf("Test #%d: %s", i, err)
+		}
+		if got := opts.Rules(); !reflect.DeepEqual(got, test.Rules) {
+			t.Errorf("Test #%d %s rules\ngot:  %v\nwant: %v", i, test.Policy, got, test.Rules)
+		}
+		if opts.QueryMode != test.QueryMode {
+			t.Errorf("Test #%d %s query mode: got %q, want %q", i, test.Policy, opts.QueryMode, test.QueryMode)
+		}
+		if !reflect.DeepEqual(opts.Secrets, test.Secrets) {
+			t.Errorf("Test #%d %s secrets\ngot:  %v\nwant: %v", i, test.Policy, opts.Secrets, test.Secrets)
+		}
+	}
+
+	// --strip-comments removes the comments kept by the policy
+	opts, _ := sanitizeOptions(sanitize.PolicyStandard, ruleSwitches{StripComments: true, KeepHints: true, CommentKeys: []string{"controller"}})
+	if opts.KeepHints || opts.CommentKeys != nil {
+		t.Errorf("Comments kept with --strip-comments: hints %v, keys %v", opts.KeepHints, opts.CommentKeys)
+	}
+	if _, err := sanitizeOptions("lenient", ruleSwitches{}); err == nil {
+		t.Errorf("No error for an unknown policy")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+
+	"github.com/Percona-Lab/sanitizer/internal/sanitize"
+	log "github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+)
+
+const (
+	// policyFile is written into the output file so support knows what was sanitized
+	policyFile = "sanitization-policy.json"
+	// policyNone is recorded when the collected data was not sanitized
+	policyNone = "none"
+)
+
+// ruleSwitches are the command line flags that change the rules of a policy
+type ruleSwitches struct {
+	NoHostnames   bool
+	NoQueries     bool
+	NoCredentials bool
+	NoEmails      bool
+	NoIPs         bool
+	NoPaths       bool
+	NoUUIDs       bool
+	NoSecrets     []string
+	Identifiers   bool
+	Users         bool
+	QueryMode     string
+	// StripComments removes all the comments of the queries, including the optimizer hints
+	StripComments      bool
+	KeepHints          bool
+	CommentKeys        []string
+	AliasCommentValues bool
+}
+
+// policyRecord is the content of the policy file
+type policyRecord struct {
+	Policy    string   `json:"policy"`
+	Rules     []string `json:"rules"`
+	QueryMode string   `json:"query_mode,omitempty"`
+	Secrets   []string `json:"secrets"`
+	Keep      []string `json:"keep,omitempty"`
+	// Comments kept in the sanitized queries
+	KeepHints          bool     `json:"keep_hints,omitempty"`
+	CommentKeys        []string `json:"comment_keys,omitempty"`
+	AliasCommentValues bool     `json:"alias_comment_values,omitempty"`
+}
+
+// sanitizeOptions returns the options of policy with the changes made by switches
+func sanitizeOptions(policy string, switches ruleSwitches) (sanitize.Options, error) {
+	opts, err := sanitize.PolicyOptions(policy)
+	if err != nil {
+		return opts, err
+	}
+	opts.Hostnames = opts.Hostnames && !switches.NoHostnames
+	opts.Queries = opts.Queries && !switches.NoQueries
+	opts.Credentials = opts.Credentials && !switches.NoCredentials
+	opts.Emails = opts.Emails && !switches.NoEmails
+	opts.IPs = opts.IPs && !switches.NoIPs
+	opts.Paths = opts.Paths && !switches.NoPaths
+	opts.UUIDs = opts.UUIDs && !switches.NoUUIDs
+	opts.Secrets = enabledSecretTypes(opts.Secrets, switches.NoSecrets)
+	opts.Identifiers = opts.Identifiers || switches.Identifiers
+	opts.Users = opts.Users || switches.Users
+	if switches.QueryMode != "" {
+		// Choosing the query mode implies sanitizing queries, even with the minimal policy
+		opts.Queries = !switches.NoQueries
+		opts.QueryMode = switches.QueryMode
+	}
+	opts.KeepHints = opts.KeepHints || switches.KeepHints
+	opts.CommentKeys = append(opts.CommentKeys, switches.CommentKeys...)
+	opts.AliasCommentValues = opts.AliasCommentValues || switches.AliasCommentValues
+	if switches.StripComments {
+		opts.KeepHints = false
+		opts.CommentKeys = nil
+	}
+	return opts, nil
+}
+
+// enabledSecretTypes returns the secret types in enabled that are not in disabled
+func enabledSecretTypes(enabled, disabled []string) []string {
+	types := []string{}
+	for _, kind := range enabled {
+		skip := false
+		for _, d := range disabled {
+			if d == kind || d == allSecretTypes {
+				skip = true
+			}
+		}
+		if !skip {
+			types = append(types, kind)
+		}
+	}
+	return types
+}
+
+// writePolicyFile records the effective sanitization policy in dir
+func writePolicyFile(dir, policy string, opts sanitize.Options) error {
+	record := policyRecord{
+		Policy:  policy,
+		Rules:   opts.Rules(),
+		Secrets: opts.Secrets,
+		Keep:    opts.Keep,
+	}
+	if record.Secrets == nil {
+		record.Secrets = []string{}
+	}
+	if opts.Queries {
+		record.QueryMode = opts.QueryMode
+		record.KeepHints = opts.KeepHints
+		record.CommentKeys = opts.CommentKeys
+		record.AliasCommentValues = opts.AliasCommentValues && len(opts.CommentKeys) > 0
+	}
+	buf, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Cannot encode the sanitization policy")
+	}
+
+	filename := path.Join(dir, policyFile)
+	log.Debugf("Writing the sanitization policy into %q", filename)
+	if err = ioutil.WriteFile(filename, append(buf, '\n'), 0644); err != nil {
+		return errors.Wrapf(err, "Cannot write the sanitization policy file %q", filename)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/Percona-Lab/sanitizer/internal/sanitize"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	ReportText = "text"
+	ReportJSON = "json"
+)
+
+type matchesReport struct {
+	File    string           `json:"file"`
+	Matches []sanitize.Match `json:"matches"`
+	Counts  map[string]int   `json:"counts"`
+}
+
+// writeMatchesReport writes the list of matches and the number of matches per rule.
+// Original values are only included if the output is a terminal.
+func writeMatchesReport(w io.Writer, format, filename string, matches []sanitize.Match) error {
+	report := matchesReport{
+		File:    filename,
+		Matches: matches,
+		Counts:  make(map[string]int),
+	}
+	showOriginals := w == os.Stdout && terminal.IsTerminal(int(os.Stdout.Fd()))
+	for i := range report.Matches {
+		report.Counts[report.Matches[i].Rule]++
+		if !showOriginals {
+			report.Matches[i].Original = ""
+		}
+	}
+
+	if format == ReportJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return errors.Wrap(err, "Cannot write the report")
+		}
+		return nil
+	}
+
+	for _, m := range report.Matches {
+		if showOriginals {
+			fmt.Fprintf(w, "%s:%d [%s] %q -> %q\n", filename, m.Line, m.Rule, m.Original, m.Replacement)
+			continue
+		}
+		fmt.Fprintf(w, "%s:%d [%s] -> %q\n", filename, m.Line, m.Rule, m.Replacement)
+	}
+
+	rules := make([]string, 0, len(report.Counts))
+	for rule := range report.Counts {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	fmt.Fprintf(w, "\nMatches per rule:\n")
+	for _, rule := range rules {
+		fmt.Fprintf(w, "  %-12s %d\n", rule+":", report.Counts[rule])
+	}
+	fmt.Fprintf(w, "  %-12s %d\n", "total:", len(report.Matches))
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/Percona-Lab/sanitizer/internal/sanitize"
+	"github.com/Percona-Lab/sanitizer/internal/sanitize/util"
+	log "github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+)
+
+func revealFile(opts *cliOptions) error {
+	mapping, err := readMappingFile(*opts.RevealMappingFile, *opts.RevealMappingPass)
+	if err != nil {
+		return err
+	}
+
+	ifh, ofh, err := openInputOutput(*opts.RevealInputFile, *opts.RevealOutputFile)
+	if err != nil {
+		return err
+	}
+	defer ifh.Close()
+	defer ofh.Close()
+
+	lines, err := util.ReadLinesFromFile(ifh)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot read input file %q", *opts.RevealInputFile)
+	}
+
+	revealed := sanitize.Reveal(lines, mapping)
+
+	if err = util.WriteLinesToFile(ofh, revealed); err != nil {
+		return errors.Wrapf(err, "Cannot write output file %q", *opts.RevealOutputFile)
+	}
+
+	return nil
+}
+
+// writeMappingFile writes the aliases mapping into filename, encrypted with password.
+// This file lets the data owner translate aliases back to the original names, so password
+// must not be the one used to encrypt the output file.
+func writeMappingFile(filename string, aliases *sanitize.Aliases, password string) error {
+	data, err := json.MarshalIndent(aliases.Mapping(), "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "Cannot encode the aliases mapping")
+	}
+	log.Infof("Writing encrypted aliases mapping file %q", filename)
+	return encryptData(data, filename, password)
+}
+
+func readMappingFile(filename, password string) (map[string]map[string]string, error) {
+	data, err := decryptData(filename, password)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read mapping file %q", filename)
+	}
+	mapping := make(map[string]map[string]string)
+	if err = json.Unmarshal(data, &mapping); err != nil {
+		return nil, errors.Wrapf(err, "Cannot decode mapping file %q", filename)
+	}
+	return mapping, nil
+}
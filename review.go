@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Percona-Lab/sanitizer/internal/sanitize/util"
+	log "github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const (
+	defaultPageSize  = 40
+	diffContextLines = 3
+	redactedText     = "<redacted>"
+)
+
+// processedFile holds the original and sanitized contents of a file so they can be reviewed
+type processedFile struct {
+	Name      string
+	Outfile   string
+	Original  []string
+	Sanitized []string
+}
+
+// reviewFiles shows the diff between the original and the sanitized files, lets the user
+// add extra strings to redact and asks for confirmation before continuing.
+func reviewFiles(files []processedFile, in io.Reader, out io.Writer) error {
+	reader := bufio.NewReader(in)
+	pageSize := defaultPageSize
+	if _, height, err := terminal.GetSize(int(os.Stdout.Fd())); err == nil && height > 2 {
+		pageSize = height - 2
+	}
+
+	for _, file := range files {
+		diff := util.UnifiedDiff(file.Name, file.Original, sanitizedLines(file.Sanitized), diffContextLines)
+		if len(diff) == 0 {
+			fmt.Fprintf(out, "%s: no changes\n", file.Name)
+			continue
+		}
+		quit, err := page(reader, out, diff, pageSize)
+		if err != nil {
+			return err
+		}
+		if quit {
+			break
+		}
+	}
+
+	redact, err := askRedactions(reader, out)
+	if err != nil {
+		return err
+	}
+	if len(redact) > 0 {
+		if err = applyRedactions(files, redact); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprint(out, "Continue with packaging and encryption? [y/N]: ")
+	answer, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return errors.Wrap(err, "Cannot read the confirmation")
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	if answer != "y" && answer != "yes" {
+		return errors.New("Review not confirmed. The output file was not created")
+	}
+	return nil
+}
+
+// page writes lines pageSize lines at a time. It returns true if the user wants to skip the
+// remaining files.
+func page(reader *bufio.Reader, out io.Writer, lines []string, pageSize int) (bool, error) {
+	for i, line := range lines {
+		fmt.Fprintln(out, line)
+		if (i+1)%pageSize != 0 || i == len(lines)-1 {
+			continue
+		}
+		fmt.Fprint(out, "-- More -- [Enter] next page, [n] next file, [q] skip all remaining files: ")
+		answer, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return false, errors.Wrap(err, "Cannot read the answer")
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "n":
+			return false, nil
+		case "q":
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func askRedactions(reader *bufio.Reader, out io.Writer) ([]string, error) {
+	fmt.Fprintln(out, "Enter extra strings to redact in all files, one per line. Leave the line empty to finish:")
+	redact := []string{}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, errors.Wrap(err, "Cannot read the strings to redact")
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return redact, nil
+		}
+		redact = append(redact, line)
+		if err == io.EOF {
+			return redact, nil
+		}
+	}
+}
+
+// applyRedactions replaces the strings to redact in all the sanitized files and rewrites them
+func applyRedactions(files []processedFile, redact []string) error {
+	pairs := []string{}
+	for _, r := range redact {
+		pairs = append(pairs, r, redactedText)
+	}
+	replacer := strings.NewReplacer(pairs...)
+
+	for _, file := range files {
+		changed := false
+		for i, line := range file.Sanitized {
+			if redacted := replacer.Replace(line); redacted != line {
+				file.Sanitized[i] = redacted
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		log.Infof("Applying redactions to %q", file.Outfile)
+		ofh, err := os.Create(file.Outfile)
+		if err != nil {
+			return errors.Wrapf(err, "Cannot open %q for writing", file.Outfile)
+		}
+		err = util.WriteLinesToFile(ofh, file.Sanitized)
+		ofh.Close()
+		if err != nil {
+			return errors.Wrapf(err, "Cannot write sanitized file %q", file.Outfile)
+		}
+	}
+	return nil
+}
+
+// sanitizedLines splits the sanitized lines, that might have multi-line queries, into
+// physical lines so they can be compared to the original ones.
+func sanitizedLines(lines []string) []string {
+	physical := []string{}
+	for _, line := range lines {
+		physical = append(physical, strings.Split(line, "\n")...)
+	}
+	return physical
+}
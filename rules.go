@@ -0,0 +1,55 @@
+package main
+
+import (
+	"path"
+
+	"github.com/go-ini/ini"
+	"github.com/pkg/errors"
+)
+
+const allowlistSection = "allowlist"
+
+// rulesConfig has the sanitization settings read from the rules file. The file uses the
+// INI format:
+//
+//	[allowlist]
+//	localhost
+//	percona.com
+//	*.cdn.example.com
+//	performance_schema
+type rulesConfig struct {
+	// Keep are the values that must not be replaced
+	Keep []string
+}
+
+// readRulesFile reads the rules file. An empty file name returns an empty configuration.
+func readRulesFile(filename string) (*rulesConfig, error) {
+	rules := &rulesConfig{}
+	if filename == "" {
+		return rules, nil
+	}
+
+	cfg, err := ini.LoadSources(ini.LoadOptions{AllowBooleanKeys: true}, filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Cannot read the rules file %q", filename)
+	}
+	if sec, err := cfg.GetSection(allowlistSection); err == nil {
+		rules.Keep = sec.KeyStrings()
+	}
+	return rules, nil
+}
+
+// keepPatterns returns the allowlist in the rules file plus the --keep patterns
+func keepPatterns(rulesFile string, keep []string) ([]string, error) {
+	rules, err := readRulesFile(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+	patterns := append(rules.Keep, keep...)
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "Invalid keep pattern %q", pattern)
+		}
+	}
+	return patterns, nil
+}
@@ -2,41 +2,117 @@ package main
 
 import (
 	"os"
+	"strings"
 
 	"github.com/Percona-Lab/sanitizer/internal/sanitize"
 	"github.com/Percona-Lab/sanitizer/internal/sanitize/util"
+	log "github.com/Sirupsen/logrus"
 	"github.com/pkg/errors"
 )
 
 func sanitizeFile(opts *cliOptions) error {
-	var err error
-	ifh := os.Stdin
-	ofh := os.Stdout
-
-	if *opts.SanitizeInputFile != "" {
-		ifh, err = os.Open(*opts.SanitizeInputFile)
-		if err != nil {
-			return errors.Wrapf(err, "Cannot open %q for reading", *opts.SanitizeInputFile)
-		}
+	outputFile := *opts.SanitizeOutputFile
+	if *opts.SanitizeDryRun {
+		// Dry run mode must not write any file
+		outputFile = ""
 	}
-
-	if *opts.SanitizeOutputFile != "" {
-		ifh, err = os.Create(*opts.SanitizeOutputFile)
-		if err != nil {
-			return errors.Wrapf(err, "Cannot create output file %q", *opts.SanitizeOutputFile)
-		}
+	ifh, ofh, err := openInputOutput(*opts.SanitizeInputFile, outputFile)
+	if err != nil {
+		return err
 	}
+	defer ifh.Close()
+	defer ofh.Close()
 
 	lines, err := util.ReadLinesFromFile(ifh)
 	if err != nil {
 		return errors.Wrapf(err, "Cannot read input file %q", *opts.SanitizeInputFile)
 	}
 
-	sanitized := sanitize.Sanitize(lines, !*opts.DontSanitizeHostnames, !*opts.DontSanitizeQueries)
+	aliases, err := newAliases(*opts.SanitizePersistent, *opts.SanitizeAliasKeyFile, *opts.SanitizeDryRun)
+	if err != nil {
+		return err
+	}
+	keep, err := keepPatterns(*opts.SanitizeRulesFile, *opts.SanitizeKeep)
+	if err != nil {
+		return err
+	}
+	sopts, err := sanitizeOptions(*opts.SanitizePolicy, ruleSwitches{
+		NoHostnames:        *opts.DontSanitizeHostnames,
+		NoQueries:          *opts.DontSanitizeQueries,
+		NoCredentials:      *opts.DontSanitizeCreds,
+		NoEmails:           *opts.DontSanitizeEmails,
+		NoIPs:              *opts.DontSanitizeIPs,
+		NoPaths:            *opts.DontSanitizePaths,
+		NoUUIDs:            *opts.DontSanitizeUUIDs,
+		NoSecrets:          *opts.DontSanitizeSecrets,
+		Identifiers:        *opts.DoSanitizeIdentifiers,
+		Users:              *opts.DoSanitizeUsers,
+		QueryMode:          *opts.SanitizeQueryMode,
+		StripComments:      *opts.SanitizeStripComments,
+		KeepHints:          *opts.SanitizeKeepHints,
+		CommentKeys:        *opts.SanitizeCommentKeys,
+		AliasCommentValues: *opts.SanitizeAliasComments,
+	})
+	if err != nil {
+		return err
+	}
+	sopts.KnownHosts = *opts.SanitizeKnownHosts
+	sopts.InternalSuffixes = *opts.SanitizeSuffixes
+	sopts.Keep = keep
+	log.Infof("Sanitization policy %q, rules: %s", *opts.SanitizePolicy, strings.Join(sopts.Rules(), ", "))
+	sanitizer := sanitize.New(sopts, aliases)
+	if sopts.Queries && sopts.QueryMode == sanitize.QueryDigest {
+		sanitizer.LearnDigests(lines)
+	}
+
+	fileType, detectedBy := *opts.SanitizeFileType, "set by --file-type"
+	if fileType == "" {
+		fileType, detectedBy = sanitize.Classify(*opts.SanitizeInputFile, lines)
+	}
+	filename := *opts.SanitizeInputFile
+	if filename == "" {
+		filename = "stdin"
+	}
+	logFileType(filename, fileType, detectedBy)
+
+	if *opts.SanitizeDryRun {
+		return writeMatchesReport(os.Stdout, *opts.SanitizeReport, filename, sanitizer.Matches(fileType, lines))
+	}
+
+	sanitized := sanitizer.SanitizeFile(fileType, lines)
 
 	if err = util.WriteLinesToFile(ofh, sanitized); err != nil {
 		return errors.Wrapf(err, "Cannot write output file %q", *opts.SanitizeOutputFile)
 	}
 
+	if *opts.SanitizeMappingFile != "" {
+		return writeMappingFile(*opts.SanitizeMappingFile, aliases, *opts.SanitizeMappingPass)
+	}
+
 	return nil
 }
+
+// openInputOutput opens the input and output files. If their names are empty, Stdin and
+// Stdout are used.
+func openInputOutput(inputFile, outputFile string) (*os.File, *os.File, error) {
+	var err error
+	ifh := os.Stdin
+	ofh := os.Stdout
+
+	if inputFile != "" {
+		ifh, err = os.Open(inputFile)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "Cannot open %q for reading", inputFile)
+		}
+	}
+
+	if outputFile != "" {
+		ofh, err = os.Create(outputFile)
+		if err != nil {
+			ifh.Close()
+			return nil, nil, errors.Wrapf(err, "Cannot create output file %q", outputFile)
+		}
+	}
+
+	return ifh, ofh, nil
+}
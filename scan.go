@@ -0,0 +1,137 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Percona-Lab/sanitizer/internal/sanitize"
+	"github.com/Percona-Lab/sanitizer/internal/sanitize/util"
+	log "github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+)
+
+// scanCmd scans a directory or a tar.gz file and fails if there are possible leaks
+func scanCmd(opts *cliOptions) error {
+	return scanPath(*opts.ScanPath, *opts.ScanSecrets, os.Stdout)
+}
+
+// scanPath scans all the files in a directory or a tar.gz file. It returns an error if
+// possible leaks were found.
+func scanPath(filename string, secrets []string, out io.Writer) error {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot scan %q", filename)
+	}
+
+	count := 0
+	report := func(name string, content []byte) error {
+		if isBinary(content) {
+			log.Debugf("Skipping binary file %q", name)
+			return nil
+		}
+		lines, err := util.ReadLines(bytes.NewReader(content))
+		if err != nil {
+			return errors.Wrapf(err, "Cannot read %q", name)
+		}
+		for _, f := range sanitize.Scan(lines, secrets) {
+			fmt.Fprintf(out, "%s:%d: %s: %s\n", name, f.Line, f.Rule, maskValue(f.Value))
+			count++
+		}
+		return nil
+	}
+
+	switch {
+	case fi.IsDir():
+		err = filepath.Walk(filename, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			if strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".aes") {
+				log.Debugf("Skipping file %q", path)
+				return nil
+			}
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				return errors.Wrapf(err, "Cannot read %q", path)
+			}
+			return report(path, content)
+		})
+	case strings.HasSuffix(filename, ".tar.gz") || strings.HasSuffix(filename, ".tgz"):
+		err = scanTarFile(filename, report)
+	default:
+		var content []byte
+		if content, err = ioutil.ReadFile(filename); err == nil {
+			err = report(filename, content)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if count > 0 {
+		return errors.Errorf("Found %d possible leaks in %q", count, filename)
+	}
+	log.Infof("No leaks found in %q", filename)
+	return nil
+}
+
+func scanTarFile(filename string, report func(string, []byte) error) error {
+	fh, err := os.Open(filename)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot open %q for reading", filename)
+	}
+	defer fh.Close()
+
+	gr, err := gzip.NewReader(fh)
+	if err != nil {
+		return errors.Wrapf(err, "Cannot read %q. Is it a decrypted tar.gz file?", filename)
+	}
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrapf(err, "Cannot read %q", filename)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return errors.Wrapf(err, "Cannot read %q from %q", header.Name, filename)
+		}
+		if err = report(filename+":"+header.Name, content); err != nil {
+			return err
+		}
+	}
+}
+
+func isBinary(content []byte) bool {
+	head := content
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	return bytes.IndexByte(head, 0) >= 0
+}
+
+// maskValue hides most of a value so the report doesn't leak it again
+func maskValue(value string) string {
+	if len(value) <= 8 {
+		return strings.Repeat("*", len(value))
+	}
+	return value[:4] + strings.Repeat("*", len(value)-4)
+}